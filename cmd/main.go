@@ -1,44 +1,165 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/t1ery/MotoBot/config"
 	"github.com/t1ery/MotoBot/internal/bot"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
 	"github.com/t1ery/MotoBot/internal/storage"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 
 	// Здесь мы запрашиваем токены и другие значения из файла конфигурации
-	configValues, err := config.GetConfigValuesFromConfig("BotToken", "ChatID", "Debug")
+	configValues, err := config.GetConfigValuesFromConfig("BotToken", "ChatID", "Communities", "Debug", "LogFormat", "MetricsAddr", "SessionTimeoutMinutes",
+		"FreshnessAskAfterDays", "FreshnessExpireAfterDays", "BumpCooldownHours",
+		"ReconcilePolicy", "ReconcileIntervalMinutes", "LeavePolicy", "LeaveGraceHours", "Owners",
+		"StatsChatID", "StatsReportHours")
 	if err != nil {
 		log.Panic(err)
 	}
 
+	// Режим отладки включает подробные логи
+	debug := configValues["Debug"].(bool)
+	logger, err := logging.New(os.Stderr, debug, configValues["LogFormat"].(string))
+	if err != nil {
+		log.Panic(err)
+	}
+	slog.SetDefault(logger)
+
+	// Сообщения библиотеки Telegram API пишем в тот же логгер
+	err = tgbotapi.SetLogger(slog.NewLogLogger(logger.Handler(), slog.LevelWarn))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Создание хранилища данных (в данном случае, в памяти) с учётом времени операций в метриках
+	dataStorage := storage.WithMetrics(storage.NewMemoryStorage())
+
+	// Флаг -restore загружает резервную копию, сделанную командой /backup, в хранилище до запуска бота
+	restorePath := flag.String("restore", "", "файл резервной копии, который загружается в хранилище при запуске")
+	flag.Parse()
+	if *restorePath != "" {
+		err := restoreBackup(context.Background(), dataStorage, *restorePath)
+		if err != nil {
+			logger.Error("Ошибка при восстановлении из резервной копии", "path", *restorePath, "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Создаем бота с использованием значений из конфигурации
 	botAPI, err := tgbotapi.NewBotAPI(configValues["BotToken"].(string))
 	if err != nil {
-		log.Panic(err)
+		logger.Error("Ошибка при создании Telegram API", "error", err)
+		os.Exit(1)
 	}
 
 	chatID, ok := configValues["ChatID"].(int64)
 	if !ok {
-		log.Panic("ChatID не является корректным int64 значением")
+		logger.Error("ChatID не является корректным int64 значением")
+		os.Exit(1)
+	}
+
+	botAPI.Debug = debug
+
+	// Если группы сообществ не перечислены, бот обслуживает одну группу ChatID
+	var communities []bot.Community
+	for _, community := range configValues["Communities"].([]config.Community) {
+		communities = append(communities, bot.Community{
+			ChatID:        community.ChatID,
+			Name:          community.Name,
+			TopicID:       community.TopicID,
+			WelcomeText:   community.WelcomeText,
+			InfoText:      community.InfoText,
+			Admins:        community.Admins,
+			Questionnaire: community.Questionnaire,
+		})
 	}
+	if len(communities) == 0 {
+		communities = append(communities, bot.Community{ChatID: chatID})
+	}
+
+	settings := bot.Settings{
+		SessionTimeout: time.Duration(configValues["SessionTimeoutMinutes"].(int)) * time.Minute,
+
+		FreshnessAskAfter:    time.Duration(configValues["FreshnessAskAfterDays"].(int)) * 24 * time.Hour,
+		FreshnessExpireAfter: time.Duration(configValues["FreshnessExpireAfterDays"].(int)) * 24 * time.Hour,
+		BumpCooldown:         time.Duration(configValues["BumpCooldownHours"].(int)) * time.Hour,
+
+		ReconcilePolicy:   configValues["ReconcilePolicy"].(string),
+		ReconcileInterval: time.Duration(configValues["ReconcileIntervalMinutes"].(int)) * time.Minute,
 
-	botAPI.Debug = configValues["Debug"].(bool)
+		LeavePolicy:      configValues["LeavePolicy"].(string),
+		LeaveGracePeriod: time.Duration(configValues["LeaveGraceHours"].(int)) * time.Hour,
 
-	// Создание хранилища данных (в данном случае, в памяти)
-	dataStorage := storage.NewMemoryStorage()
+		Owners: configValues["Owners"].([]int),
 
-	b, err := bot.NewBot(botAPI.Token, dataStorage, chatID)
+		StatsChatID:   configValues["StatsChatID"].(int64),
+		StatsInterval: time.Duration(configValues["StatsReportHours"].(int)) * time.Hour,
+	}
+
+	b, err := bot.NewBot(botAPI.Token, dataStorage, communities, settings, logger)
 	if err != nil {
-		log.Panic(err)
+		logger.Error("Ошибка при создании бота", "error", err)
+		os.Exit(1)
+	}
+
+	// Останавливаем бота по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Эндпоинт /metrics запускается, только если в конфигурации указан его адрес
+	if metricsAddr := configValues["MetricsAddr"].(string); metricsAddr != "" {
+		go func() {
+			logger.Info("Сервер метрик запущен", "addr", metricsAddr)
+			if err := metrics.Serve(ctx, metricsAddr); err != nil {
+				logger.Error("Ошибка сервера метрик", "error", err)
+			}
+		}()
 	}
 
 	// Добавляем лог для сообщения о запуске бота
-	log.Println("Бот запущен!")
+	logger.Info("Бот запущен!")
+
+	if err := b.Run(ctx); err != nil {
+		logger.Error("Ошибка при работе бота", "error", err)
+	}
+
+	// Закрываем хранилище после того, как бот завершил все операции с ним
+	if err := dataStorage.Close(); err != nil {
+		logger.Error("Ошибка при закрытии хранилища", "error", err)
+	}
 
-	b.Run()
+	logger.Info("Бот остановлен")
+}
+
+// restoreBackup загружает в хранилище данные из файла резервной копии.
+func restoreBackup(ctx context.Context, dataStorage storage.Storage, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	manifest, err := storage.Restore(ctx, dataStorage, file, info.Size())
+	if err != nil {
+		return err
+	}
+	slog.Info("Данные восстановлены из резервной копии", "path", path, "version", manifest.Version,
+		"created_at", manifest.CreatedAt, "profiles", manifest.Profiles, "photos", manifest.Photos,
+		"sessions", manifest.Sessions, "grants", manifest.Grants, "audit", manifest.Audit, "wizard", manifest.Wizard)
+	return nil
 }
@@ -6,11 +6,42 @@ import (
 	"os"
 )
 
+// Community - настройки группы сообщества
+type Community struct {
+	ChatID        int64  `yaml:"ChatID"`
+	Name          string `yaml:"Name"`          // Название сообщества, например город
+	TopicID       int    `yaml:"TopicID"`       // Тема группы, в которую публикуются анкеты. 0 - общий чат
+	WelcomeText   string `yaml:"WelcomeText"`   // Приветствие новых участников. Пустое - стандартное
+	InfoText      string `yaml:"InfoText"`      // Текст команды /info. Пустой - стандартный
+	Admins        []int  `yaml:"Admins"`        // Идентификаторы администраторов бота в сообществе
+	Questionnaire string `yaml:"Questionnaire"` // Вариант анкеты: full или short
+}
+
 // Структура для конфигурации
 type Config struct {
-	BotToken string `yaml:"BotToken"`
-	ChatID   int64  `yaml:"ChatID"`
-	Debug    bool   `yaml:"Debug"`
+	BotToken    string      `yaml:"BotToken"`
+	ChatID      int64       `yaml:"ChatID"`      // Группа по умолчанию, если список Communities пуст
+	Communities []Community `yaml:"Communities"` // Группы сообществ, которые обслуживает бот
+	Debug       bool        `yaml:"Debug"`
+	LogFormat   string      `yaml:"LogFormat"`   // Формат логов: text или json
+	MetricsAddr string      `yaml:"MetricsAddr"` // Адрес HTTP-сервера метрик, например ":9090". Пустой - метрики отключены
+
+	SessionTimeoutMinutes int `yaml:"SessionTimeoutMinutes"` // Время бездействия в минутах, после которого незавершённая анкета удаляется
+
+	FreshnessAskAfterDays    int `yaml:"FreshnessAskAfterDays"`    // Через сколько дней без изменений спрашивать владельца об актуальности анкеты. 0 - не спрашивать
+	FreshnessExpireAfterDays int `yaml:"FreshnessExpireAfterDays"` // Сколько дней ждать ответа, прежде чем снять анкету из группы
+	BumpCooldownHours        int `yaml:"BumpCooldownHours"`        // Как часто (в часах) можно поднимать анкету командой /up
+
+	ReconcilePolicy          string `yaml:"ReconcilePolicy"`          // Что делать с анкетой, сообщение с которой пропало из группы: republish или clear
+	ReconcileIntervalMinutes int    `yaml:"ReconcileIntervalMinutes"` // Как часто (в минутах) анкеты сверяются с сообщениями в группе
+
+	LeavePolicy     string `yaml:"LeavePolicy"`     // Что делать с анкетой участника, покинувшего группу: delete, hide или keep
+	LeaveGraceHours int    `yaml:"LeaveGraceHours"` // Сколько часов ждать возвращения участника, прежде чем применить политику
+
+	Owners []int `yaml:"Owners"` // Идентификаторы владельцев бота, которым доступны все команды
+
+	StatsChatID      int64 `yaml:"StatsChatID"`      // Чат администраторов для планового отчёта со статистикой. 0 - не отправлять
+	StatsReportHours int   `yaml:"StatsReportHours"` // Как часто (в часах) отправляется плановый отчёт
 }
 
 // GetConfigValuesFromConfig функция для извлечения нескольких значений из config.yaml
@@ -41,8 +72,36 @@ func GetConfigValuesFromConfig(keys ...string) (map[string]interface{}, error) {
 			configValues[key] = cfg.BotToken
 		case "ChatID":
 			configValues[key] = cfg.ChatID
+		case "Communities":
+			configValues[key] = cfg.Communities
 		case "Debug":
 			configValues[key] = cfg.Debug
+		case "LogFormat":
+			configValues[key] = cfg.LogFormat
+		case "MetricsAddr":
+			configValues[key] = cfg.MetricsAddr
+		case "SessionTimeoutMinutes":
+			configValues[key] = cfg.SessionTimeoutMinutes
+		case "FreshnessAskAfterDays":
+			configValues[key] = cfg.FreshnessAskAfterDays
+		case "FreshnessExpireAfterDays":
+			configValues[key] = cfg.FreshnessExpireAfterDays
+		case "BumpCooldownHours":
+			configValues[key] = cfg.BumpCooldownHours
+		case "ReconcilePolicy":
+			configValues[key] = cfg.ReconcilePolicy
+		case "ReconcileIntervalMinutes":
+			configValues[key] = cfg.ReconcileIntervalMinutes
+		case "LeavePolicy":
+			configValues[key] = cfg.LeavePolicy
+		case "LeaveGraceHours":
+			configValues[key] = cfg.LeaveGraceHours
+		case "Owners":
+			configValues[key] = cfg.Owners
+		case "StatsChatID":
+			configValues[key] = cfg.StatsChatID
+		case "StatsReportHours":
+			configValues[key] = cfg.StatsReportHours
 		default:
 			return nil, errors.New("Неизвестный ключ конфигурации: " + key)
 		}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// adminSyncInterval - как часто обновляются списки администраторов групп
+const adminSyncInterval = 15 * time.Minute
+
+// commandRoles - минимальная роль для каждой команды бота. Команда, которой нет в списке,
+// считается неизвестной, поэтому каждый новый обработчик команды должен объявить здесь свою роль.
+var commandRoles = map[string]user.Role{
+	"info":      user.RoleMember,
+	"start":     user.RoleMember,
+	"cancel":    user.RoleMember,
+	"edit":      user.RoleMember,
+	"delete":    user.RoleMember,
+	"hide":      user.RoleMember,
+	"show":      user.RoleMember,
+	"up":        user.RoleMember,
+	"myprofile": user.RoleMember,
+	"find":      user.RoleMember,
+	"mydata":    user.RoleMember,
+	"forget_me": user.RoleMember,
+	"broadcast": user.RoleOrganizer,
+	"export":    user.RoleOrganizer,
+	"stats":     user.RoleAdmin,
+	"reconcile": user.RoleAdmin,
+	"grant":     user.RoleAdmin,
+	"revoke":    user.RoleAdmin,
+	"history":   user.RoleAdmin,
+	"backup":    user.RoleOwner,
+}
+
+// syncAdmins загружает администраторов всех групп сообществ из Telegram.
+func (b *MotoBot) syncAdmins(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	for _, chatID := range b.communityOrder {
+		start := time.Now()
+		members, err := b.bot.GetChatAdministrators(tgbotapi.ChatConfig{ChatID: chatID})
+		metrics.ObserveTelegram("getChatAdministrators", start, err)
+		if err != nil {
+			logger.Error("Ошибка при получении администраторов группы", "error", err, "chat_id", chatID)
+			continue
+		}
+
+		admins := make(map[int]bool, len(members))
+		for _, member := range members {
+			if member.User != nil && !member.User.IsBot {
+				admins[member.User.ID] = true
+			}
+		}
+		b.chatAdmins[chatID] = admins
+	}
+}
+
+// roleOf определяет роль пользователя в сообществе: владельцы бота и администраторы берутся
+// из конфигурации, администраторы групп - из Telegram, организаторы - из выданных ролей.
+func (b *MotoBot) roleOf(ctx context.Context, userID int, chatID int64) (user.Role, error) {
+	for _, owner := range b.settings.Owners {
+		if owner == userID {
+			return user.RoleOwner, nil
+		}
+	}
+	for _, admin := range b.community(chatID).Admins {
+		if admin == userID {
+			return user.RoleAdmin, nil
+		}
+	}
+	if b.chatAdmins[chatID][userID] {
+		return user.RoleAdmin, nil
+	}
+
+	grants, err := b.dataStorage.ListGrants(ctx)
+	if err != nil {
+		return user.RoleMember, err
+	}
+	for _, grant := range grants {
+		if grant.ChatID == chatID && grant.UserID == userID {
+			return grant.Role, nil
+		}
+	}
+	return user.RoleMember, nil
+}
+
+// authorize проверяет, может ли пользователь выполнить команду в сообществе.
+// Если не может, пользователь получает объяснение.
+func (b *MotoBot) authorize(ctx context.Context, command string, userID int, chatID, replyChatID int64) bool {
+	required, ok := commandRoles[command]
+	if !ok {
+		err := b.sendUnknownCommandMessage(ctx, replyChatID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Ошибка при отправке сообщения с неизвестной командой", "error", err)
+		}
+		return false
+	}
+	if required == user.RoleMember {
+		return true
+	}
+
+	role, err := b.roleOf(ctx, userID, chatID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Ошибка при определении роли пользователя", "error", err)
+		return false
+	}
+	if role >= required {
+		return true
+	}
+
+	logging.FromContext(ctx).Info("Недостаточно прав для команды", "role", user.RoleName(role), "required", user.RoleName(required))
+	message := tgbotapi.NewMessage(replyChatID, fmt.Sprintf("Недостаточно прав для команды /%s.", command))
+	_, err = b.send(ctx, message)
+	if err != nil {
+		logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", err)
+	}
+	return false
+}
+
+// commandTarget определяет пользователя, к которому относится команда: автора сообщения,
+// на которое ответили командой, или пользователя с идентификатором из аргументов.
+func commandTarget(message *tgbotapi.Message) (int, bool) {
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		return message.ReplyToMessage.From.ID, true
+	}
+	userID, err := strconv.Atoi(message.CommandArguments())
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// Grant назначает пользователя организатором сообщества.
+func (b *MotoBot) Grant(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	targetID, ok := commandTarget(message)
+	if !ok {
+		reply := tgbotapi.NewMessage(message.Chat.ID, "Ответьте командой /grant на сообщение пользователя или укажите его идентификатор: /grant 123456.")
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	err := b.dataStorage.SaveGrant(ctx, &user.Grant{
+		ChatID:    chatID,
+		UserID:    targetID,
+		Role:      user.RoleOrganizer,
+		GrantedBy: message.From.ID,
+		GrantedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Назначен организатор", "target_id", targetID)
+	b.audit(ctx, user.AuditEntry{
+		ChatID:  chatID,
+		UserID:  targetID,
+		ActorID: message.From.ID,
+		Action:  user.AuditGranted,
+		Comment: user.RoleName(user.RoleOrganizer),
+	})
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Пользователь %d назначен организатором.", targetID))
+	_, err = b.send(ctx, reply)
+	return err
+}
+
+// Revoke снимает с пользователя роль организатора сообщества.
+func (b *MotoBot) Revoke(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	targetID, ok := commandTarget(message)
+	if !ok {
+		reply := tgbotapi.NewMessage(message.Chat.ID, "Ответьте командой /revoke на сообщение пользователя или укажите его идентификатор: /revoke 123456.")
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	err := b.dataStorage.DeleteGrant(ctx, chatID, targetID)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Снята роль организатора", "target_id", targetID)
+	b.audit(ctx, user.AuditEntry{
+		ChatID:  chatID,
+		UserID:  targetID,
+		ActorID: message.From.ID,
+		Action:  user.AuditRevoked,
+		Comment: user.RoleName(user.RoleOrganizer),
+	})
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Пользователь %d больше не организатор.", targetID))
+	_, err = b.send(ctx, reply)
+	return err
+}
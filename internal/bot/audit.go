@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// historyLimit - сколько последних записей журнала показывает команда /history
+const historyLimit = 30
+
+// audit добавляет запись в журнал аудита. Ошибка записи журнала не прерывает само действие.
+func (b *MotoBot) audit(ctx context.Context, entry user.AuditEntry) {
+	entry.CreatedAt = time.Now()
+	err := b.dataStorage.AppendAudit(ctx, &entry)
+	if err != nil {
+		logging.FromContext(ctx).Error("Ошибка записи в журнал аудита", "error", err, "user_id", entry.UserID, "action", entry.Action)
+	}
+}
+
+// auditProfile записывает в журнал действие с анкетой.
+func (b *MotoBot) auditProfile(ctx context.Context, profile *user.Profile, actorID int, action, comment string) {
+	b.audit(ctx, user.AuditEntry{
+		ChatID:  profile.ChatID,
+		UserID:  profile.UserID,
+		ActorID: actorID,
+		Action:  action,
+		Comment: comment,
+	})
+}
+
+// rememberUsernames запоминает username авторов обновления, чтобы /history @username находила
+// пользователя без запросов к Telegram: бот не может найти пользователя по username.
+func (b *MotoBot) rememberUsernames(update tgbotapi.Update) {
+	var users []*tgbotapi.User
+	switch {
+	case update.Message != nil:
+		users = append(users, update.Message.From)
+		if update.Message.ReplyToMessage != nil {
+			users = append(users, update.Message.ReplyToMessage.From)
+		}
+		if update.Message.NewChatMembers != nil {
+			for i := range *update.Message.NewChatMembers {
+				users = append(users, &(*update.Message.NewChatMembers)[i])
+			}
+		}
+	case update.CallbackQuery != nil:
+		users = append(users, update.CallbackQuery.From)
+	case update.InlineQuery != nil:
+		users = append(users, update.InlineQuery.From)
+	}
+
+	for _, from := range users {
+		if from != nil && from.UserName != "" {
+			b.usernames[strings.ToLower(from.UserName)] = from.ID
+		}
+	}
+}
+
+// forgetUsername удаляет username пользователя из запомненных.
+func (b *MotoBot) forgetUsername(userID int) {
+	for username, id := range b.usernames {
+		if id == userID {
+			delete(b.usernames, username)
+		}
+	}
+}
+
+// historyTarget определяет пользователя для команды /history: по ответу на сообщение, упоминанию,
+// идентификатору или @username. Username ищется среди пользователей, которых бот видел в обновлениях,
+// так как перебор участников запросами к Telegram надолго остановил бы обработку обновлений.
+func (b *MotoBot) historyTarget(message *tgbotapi.Message) (int, bool) {
+	if message.Entities != nil {
+		for _, entity := range *message.Entities {
+			if entity.Type == "text_mention" && entity.User != nil {
+				return entity.User.ID, true
+			}
+		}
+	}
+	if userID, ok := commandTarget(message); ok {
+		return userID, true
+	}
+
+	username, ok := strings.CutPrefix(strings.TrimSpace(message.CommandArguments()), "@")
+	if !ok || username == "" {
+		return 0, false
+	}
+	userID, ok := b.usernames[strings.ToLower(username)]
+	return userID, ok
+}
+
+// History отправляет администратору в личные сообщения историю изменений анкеты и ролей пользователя
+// в сообществе. В группе история не показывается, так как в ней есть личные данные участника.
+func (b *MotoBot) History(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	adminID := int64(message.From.ID)
+
+	entries, err := b.dataStorage.ListAudit(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetID, ok := b.historyTarget(message)
+	if !ok {
+		reply := tgbotapi.NewMessage(adminID, "Пользователь не найден. Ответьте командой /history на сообщение пользователя "+
+			"или укажите его: /history @username или /history 123456. По username находятся только пользователи, "+
+			"которые писали в группе или боту после его запуска.")
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	var history []*user.AuditEntry
+	for _, entry := range entries {
+		if entry.ChatID == chatID && entry.UserID == targetID {
+			history = append(history, entry)
+		}
+	}
+
+	if len(history) == 0 {
+		reply := tgbotapi.NewMessage(adminID, fmt.Sprintf("В журнале нет записей о пользователе %d.", targetID))
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "История пользователя %d", targetID)
+	if len(history) > historyLimit {
+		fmt.Fprintf(&text, " (последние %d из %d)", historyLimit, len(history))
+		history = history[len(history)-historyLimit:]
+	}
+	text.WriteString(":\n")
+	for _, entry := range history {
+		text.WriteString("\n" + describeAudit(entry))
+	}
+
+	reply := tgbotapi.NewMessage(adminID, text.String())
+	_, err = b.send(ctx, reply)
+	return err
+}
+
+// describeAudit описывает запись журнала одной или несколькими строками.
+func describeAudit(entry *user.AuditEntry) string {
+	actor := "бот"
+	switch {
+	case entry.ActorID == entry.UserID:
+		actor = "владелец"
+	case entry.ActorID != 0:
+		actor = fmt.Sprintf("пользователь %d", entry.ActorID)
+	}
+
+	line := fmt.Sprintf("%s - %s, %s", entry.CreatedAt.Format("02.01.2006 15:04"), user.AuditActionName(entry.Action), actor)
+	if entry.Comment != "" {
+		line += " (" + entry.Comment + ")"
+	}
+	for _, change := range entry.Changes {
+		line += fmt.Sprintf("\n  %s: %q → %q", change.Field, change.Old, change.New)
+	}
+	return line
+}
@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/storage"
+)
+
+// Backup отправляет владельцу бота в личные сообщения резервную копию всех данных хранилища.
+// Команды обрабатываются по очереди, поэтому архив не захватывает частично применённые изменения.
+// Загрузить архив обратно можно при запуске бота с флагом -restore.
+func (b *MotoBot) Backup(ctx context.Context, message *tgbotapi.Message) error {
+	var buf bytes.Buffer
+	manifest, err := storage.Backup(ctx, b.dataStorage, &buf)
+	if err != nil {
+		return err
+	}
+
+	name := "motobot-backup-" + time.Now().Format("2006-01-02-150405") + ".zip"
+	document := tgbotapi.NewDocumentUpload(int64(message.From.ID), tgbotapi.FileBytes{Name: name, Bytes: buf.Bytes()})
+	document.Caption = fmt.Sprintf("Резервная копия, версия формата %d\nАнкет: %d, фотографий: %d, незавершённых анкет: %d, ролей: %d, записей журнала: %d",
+		manifest.Version, manifest.Profiles, manifest.Photos, manifest.Sessions, manifest.Grants, manifest.Audit)
+	_, err = b.send(ctx, document)
+	return err
+}
@@ -1,510 +1,829 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/t1ery/MotoBot/config"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
 	"github.com/t1ery/MotoBot/internal/storage"
 	"github.com/t1ery/MotoBot/internal/user"
 )
 
+// errMessageNotEditable - сообщение с анкетой нельзя изменить на месте.
+var errMessageNotEditable = errors.New("сообщение нельзя изменить на месте")
+
+// Данные инлайн кнопок подтверждения удаления анкеты. К кнопке подтверждения через двоеточие добавляется группа анкеты
+const (
+	callbackDeleteConfirm = "delete_confirm"
+	callbackDeleteCancel  = "delete_cancel"
+)
+
 // Bot представляет интерфейс для взаимодействия с ботом.
 type Bot interface {
-	CreateProfile(userID int, chatID int64, updates <-chan tgbotapi.Update) error // Создание анкеты
-	EditProfile(userID int, chatID int64, updates <-chan tgbotapi.Update) error   // Редактирование анкеты
-	DeleteProfile(userID int) error                                               // Удаление анкеты
-	SendProfile(userID int, chatID int64, profile *user.Profile) error            // Отправка анкеты в соответствующую тему
-	GetProjectInfo(chatID int64) error                                            // Предоставление информации о проекте пользователю
-	Run()                                                                         // Запуск бота
+	CreateProfile(ctx context.Context, userID int, chatID int64) error                      // Создание анкеты
+	CancelProfile(ctx context.Context, userID int) error                                    // Отмена заполнения анкеты
+	EditProfile(ctx context.Context, userID int, chatID int64) error                        // Редактирование анкеты
+	DeleteProfile(ctx context.Context, userID int, chatID int64) error                      // Удаление анкеты
+	HideProfile(ctx context.Context, userID int, chatID int64) error                        // Снятие анкеты из группы без удаления
+	ShowProfile(ctx context.Context, userID int, chatID int64) error                        // Возвращение скрытой анкеты в группу
+	BumpProfile(ctx context.Context, userID int, chatID int64) error                        // Поднятие анкеты вниз чата группы
+	MyProfile(ctx context.Context, userID int, chatID int64) error                          // Просмотр своей анкеты в личных сообщениях
+	FindProfiles(ctx context.Context, userID int, chatID int64, query string) error         // Поиск анкет по условиям
+	SendProfile(ctx context.Context, userID int, chatID int64, profile *user.Profile) error // Отправка анкеты в соответствующую тему
+	GetProjectInfo(ctx context.Context, chatID int64, communityID int64) error              // Предоставление информации о проекте пользователю
+	Run(ctx context.Context) error                                                          // Запуск бота до отмены контекста
+}
+
+// outboxSize - размер очереди исходящих сообщений
+const outboxSize = 100
+
+// sessionCheckInterval - как часто проверяются брошенные анкеты
+const sessionCheckInterval = time.Minute
+
+// Settings - настройки поведения бота
+type Settings struct {
+	SessionTimeout time.Duration // Время бездействия, после которого незавершённая анкета удаляется
+
+	FreshnessAskAfter    time.Duration // Через сколько после последнего действия спрашивать об актуальности анкеты. 0 - не спрашивать
+	FreshnessExpireAfter time.Duration // Сколько ждать ответа, прежде чем снять анкету из группы
+	BumpCooldown         time.Duration // Как часто можно поднимать анкету командой /up
+
+	ReconcilePolicy   string        // Что делать с анкетой, сообщение с которой пропало из группы: ReconcileRepublish или ReconcileClear
+	ReconcileInterval time.Duration // Как часто анкеты сверяются с сообщениями в группе
+
+	LeavePolicy      string        // Что делать с анкетой участника, покинувшего группу: LeaveDelete, LeaveHide или LeaveKeep
+	LeaveGracePeriod time.Duration // Сколько ждать возвращения участника, прежде чем применить политику. 0 - применять сразу
+
+	Owners []int // Владельцы бота, которым доступны все команды во всех сообществах
+
+	StatsChatID   int64         // Чат администраторов для планового отчёта со статистикой. 0 - отчёт не отправляется
+	StatsInterval time.Duration // Как часто отправляется плановый отчёт
 }
 
 // MotoBot представляет реализацию интерфейса Bot.
 type MotoBot struct {
 	bot         *tgbotapi.BotAPI
 	dataStorage storage.Storage
-	chatID      int64
 	token       string
+	outbox      *outbox
+	logger      *slog.Logger
+	settings    Settings
+
+	// Сообщества, которые обслуживает бот, по идентификатору группы и в порядке из конфигурации
+	communities      map[int64]*Community
+	communityOrder   []int64
+	defaultCommunity *Community
+
+	// Обновления обрабатываются по одному, поэтому для следующих полей блокировка не нужна
+	searches      map[int]*search        // Поиски анкет по пользователям
+	lastCommunity map[int]int64          // Последние группы, в которые вступили пользователи или в которых отправляли команды
+	usernames     map[string]int         // Пользователи по username в нижнем регистре, замеченные в обновлениях
+	chatAdmins    map[int64]map[int]bool // Администраторы групп по данным Telegram
+	broadcasts    map[int]*broadcast     // Рассылки, ожидающие подтверждения, по авторам
+
+	reconciling bool                // Идёт сверка анкет с сообщениями в группе
+	reconciled  chan reconcileCheck // Результаты проверки сообщений, найденные в фоне
+
+	// background - фоновые задачи, например рассылки, которые нужно дождаться при остановке
+	background sync.WaitGroup
 }
 
-// NewBot создает новый экземпляр бота.
-func NewBot(token string, dataStorage storage.Storage, chatID int64) (Bot, error) {
+// NewBot создает новый экземпляр бота, обслуживающего группы сообществ.
+func NewBot(token string, dataStorage storage.Storage, communities []Community, settings Settings, logger *slog.Logger) (Bot, error) {
+	if len(communities) == 0 {
+		return nil, errors.New("не указано ни одной группы")
+	}
+
+	communityByChat := make(map[int64]*Community, len(communities))
+	communityOrder := make([]int64, 0, len(communities))
+	for i := range communities {
+		community := &communities[i]
+		switch community.Questionnaire {
+		case "":
+			community.Questionnaire = QuestionnaireFull
+		case QuestionnaireFull, QuestionnaireShort:
+		default:
+			return nil, fmt.Errorf("неизвестный вариант анкеты группы %d: %q", community.ChatID, community.Questionnaire)
+		}
+		if _, ok := communityByChat[community.ChatID]; ok {
+			return nil, fmt.Errorf("группа %d указана несколько раз", community.ChatID)
+		}
+		communityByChat[community.ChatID] = community
+		communityOrder = append(communityOrder, community.ChatID)
+	}
+
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, err
 	}
 
+	if settings.SessionTimeout <= 0 {
+		settings.SessionTimeout = defaultSessionTimeout
+	}
+	if settings.FreshnessExpireAfter <= 0 {
+		settings.FreshnessExpireAfter = settings.FreshnessAskAfter
+	}
+	if settings.BumpCooldown <= 0 {
+		settings.BumpCooldown = defaultBumpCooldown
+	}
+	switch settings.ReconcilePolicy {
+	case "":
+		settings.ReconcilePolicy = ReconcileClear
+	case ReconcileRepublish, ReconcileClear:
+	default:
+		return nil, fmt.Errorf("неизвестная политика сверки анкет: %q", settings.ReconcilePolicy)
+	}
+	if settings.ReconcileInterval <= 0 {
+		settings.ReconcileInterval = defaultReconcileInterval
+	}
+	if settings.StatsInterval <= 0 {
+		settings.StatsInterval = defaultStatsInterval
+	}
+	switch settings.LeavePolicy {
+	case "":
+		settings.LeavePolicy = LeaveHide
+	case LeaveDelete, LeaveHide, LeaveKeep:
+	default:
+		return nil, fmt.Errorf("неизвестная политика для анкет покинувших группу: %q", settings.LeavePolicy)
+	}
+
 	return &MotoBot{
 		bot:         bot,
 		dataStorage: dataStorage,
-		chatID:      chatID,
 		token:       token,
+		outbox:      newOutbox(outboxSize),
+		logger:      logger,
+		settings:    settings,
+
+		communities:      communityByChat,
+		communityOrder:   communityOrder,
+		defaultCommunity: communityByChat[communityOrder[0]],
+
+		searches:      make(map[int]*search),
+		lastCommunity: make(map[int]int64),
+		usernames:     make(map[string]int),
+		chatAdmins:    make(map[int64]map[int]bool),
+		broadcasts:    make(map[int]*broadcast),
+		reconciled:    make(chan reconcileCheck),
 	}, nil
 }
 
-// Run запускает бота и начинает обработку обновлений.
-func (b *MotoBot) Run() {
-
-	// Здесь мы запрашиваем ChatID из файла конфигурации
-	configValues, err := config.GetConfigValuesFromConfig("ChatID")
-	if err != nil {
-		log.Panic(err)
-	}
-
-	chatID, ok := configValues["ChatID"].(int64)
-	if !ok {
-		log.Panic("ChatID не является корректным int64 значением")
+// Run запускает бота и обрабатывает обновления до отмены контекста.
+// При остановке прекращается получение обновлений и отправляются все сообщения из очереди.
+func (b *MotoBot) Run(ctx context.Context) error {
+	for _, chatID := range b.communityOrder {
+		b.logger.Info("Бот подписан на обновления к чату", "chat_id", chatID, "community", b.communities[chatID].Name)
 	}
 
-	log.Printf("Бот подписан на обновления к чату - ChatID: %d\n", b.chatID)
-
 	// Настроим обработку обновлений
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 60
 
 	updates, err := b.bot.GetUpdatesChan(updateConfig)
 	if err != nil {
-		log.Panic(err)
-	}
-
-	for update := range updates {
-		// Обработка каждого обновления
-		if update.Message != nil {
-			// Проверяем событие вступления новых участников
-			if update.Message.NewChatMembers != nil {
-				for _, newUser := range *update.Message.NewChatMembers {
-					// Приветствуем нового участника и отправляем инлайн клавиатуру
-					err := b.welcomeNewUser(newUser.ID, update.Message.Chat.ID)
-					if err != nil {
-						log.Printf("Ошибка при приветствии нового участника: %v", err)
-					}
+		return err
+	}
+	defer b.shutdown()
+
+	// Роли администраторов групп нужны с первой же команды
+	b.syncAdmins(logging.WithLogger(ctx, b.logger))
+	adminTicker := time.NewTicker(adminSyncInterval)
+	defer adminTicker.Stop()
+
+	statsTicker := time.NewTicker(b.settings.StatsInterval)
+	defer statsTicker.Stop()
+
+	sessionTicker := time.NewTicker(sessionCheckInterval)
+	defer sessionTicker.Stop()
+
+	freshnessTicker := time.NewTicker(freshnessCheckInterval)
+	defer freshnessTicker.Stop()
+
+	reconcileTicker := time.NewTicker(b.settings.ReconcileInterval)
+	defer reconcileTicker.Stop()
+
+	departureTicker := time.NewTicker(departureCheckInterval)
+	defer departureTicker.Stop()
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			b.logger.Info("Получен сигнал остановки, завершаем работу бота")
+			return nil
+		case <-sessionTicker.C:
+			b.expireSessions(logging.WithLogger(ctx, b.logger))
+			continue
+		case <-freshnessTicker.C:
+			b.checkFreshness(logging.WithLogger(ctx, b.logger))
+			continue
+		case <-reconcileTicker.C:
+			b.runReconcile(logging.WithLogger(ctx, b.logger))
+			continue
+		case check := <-b.reconciled:
+			b.applyReconcile(logging.WithLogger(ctx, b.logger), check)
+			continue
+		case <-departureTicker.C:
+			b.checkDepartures(logging.WithLogger(ctx, b.logger))
+			continue
+		case <-adminTicker.C:
+			b.syncAdmins(logging.WithLogger(ctx, b.logger))
+			continue
+		case <-statsTicker.C:
+			b.postStatsReport(logging.WithLogger(ctx, b.logger))
+			continue
+		case update = <-updates:
+		}
+
+		b.handleUpdate(logging.WithLogger(ctx, updateLogger(b.logger, update)), update)
+	}
+}
+
+// handleUpdate обрабатывает одно обновление от Telegram.
+func (b *MotoBot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Получено обновление")
+	metrics.UpdatesProcessed.WithLabelValues(updateType(update)).Inc()
+	b.rememberUsernames(update)
+
+	// Ответы на шаги анкеты передаются мастеру заполнения
+	session, err := b.wizardSession(ctx, update)
+	if err != nil {
+		logger.Error("Ошибка при получении незавершённой анкеты", "error", err)
+		return
+	}
+	if session != nil {
+		ctx = logging.WithLogger(ctx, logger.With(slog.String("step", user.StepName(session.Step))))
+		err := b.handleWizardUpdate(ctx, session, update)
+		if err != nil {
+			logging.FromContext(ctx).Error("Ошибка при заполнении анкеты", "error", err)
+		}
+		return
+	}
+
+	if update.Message != nil {
+		// Проверяем событие вступления новых участников
+		if update.Message.NewChatMembers != nil {
+			// Участники групп, которые бот не обслуживает, не приветствуются
+			if !b.isCommunity(update.Message.Chat.ID) {
+				return
+			}
+			for _, newUser := range *update.Message.NewChatMembers {
+				// Анкета участника будет опубликована в группе, в которую он вступил
+				b.lastCommunity[newUser.ID] = update.Message.Chat.ID
+
+				// Вернувшийся в течение льготного периода участник сохраняет анкету
+				err := b.memberReturned(ctx, update.Message.Chat.ID, newUser.ID)
+				if err != nil {
+					logger.Error("Ошибка при возвращении участника в группу", "error", err)
 				}
-			} else if update.Message.IsCommand() {
-				// Обработка текстовых команд
-				switch update.Message.Command() {
-				case "info":
-					// Обработка команды "/info"
-					err := b.GetProjectInfo(update.Message.Chat.ID)
-					if err != nil {
-						log.Printf("Ошибка при отправке информации: %v", err)
-					}
-				case "start":
-					// Обработка команды "/start"
-					err := b.CreateProfile(update.Message.From.ID, chatID, updates)
-					if err != nil {
-						log.Printf("Ошибка при создании анкеты: %v", err)
-					}
-				case "edit":
-					// Обработка команды "/edit"
-					err := b.EditProfile(update.Message.From.ID, chatID, updates)
-					if err != nil {
-						log.Printf("Ошибка при попытке редактирования анкеты: %v", err)
-					}
-				case "delete":
-					// Обработка команды "/delete"
-					err := b.DeleteProfile(update.Message.From.ID)
-					if err != nil {
-						log.Printf("Ошибка при попытке удаления анкеты: %v", err)
-					}
-				default:
-					// Обработка неизвестных команд
-					err := b.sendUnknownCommandMessage(update.Message.Chat.ID)
-					if err != nil {
-						log.Printf("Ошибка при отправке сообщения с неизвестной командой: %v", err)
-					}
+
+				// Приветствуем нового участника и отправляем инлайн клавиатуру
+				err = b.welcomeNewUser(ctx, newUser.ID, update.Message.Chat.ID)
+				if err != nil {
+					logger.Error("Ошибка при приветствии нового участника", "error", err)
 				}
 			}
-		}
-
-		// Обработка текстовых команд
-		if update.CallbackQuery != nil {
-			// Получаем данные, связанные с CallbackQuery
-			callbackData := update.CallbackQuery.Data
-			switch callbackData {
-			case "/info":
-				// Обработка команды "Информация"
-				err := b.GetProjectInfo(update.CallbackQuery.Message.Chat.ID)
+		} else if update.Message.LeftChatMember != nil {
+			// Участник вышел из группы или был удалён из неё
+			if b.isCommunity(update.Message.Chat.ID) {
+				err := b.memberLeft(ctx, update.Message.Chat.ID, update.Message.LeftChatMember.ID)
+				if err != nil {
+					logger.Error("Ошибка при обработке выхода участника из группы", "error", err)
+				}
+			}
+		} else if update.Message.IsCommand() {
+			// Обработка текстовых команд
+			commandMetric(update.Message.Command())
+			chatID := b.resolveCommunity(ctx, update.Message.From.ID, update.Message.Chat)
+			if !b.authorize(ctx, update.Message.Command(), update.Message.From.ID, chatID, update.Message.Chat.ID) {
+				return
+			}
+			switch update.Message.Command() {
+			case "info":
+				// Обработка команды "/info"
+				err := b.GetProjectInfo(ctx, update.Message.Chat.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при отправке информации", "error", err)
+				}
+			case "start":
+				// Обработка команды "/start"
+				err := b.CreateProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при создании анкеты", "error", err)
+				}
+			case "cancel":
+				// Обработка команды "/cancel"
+				err := b.CancelProfile(ctx, update.Message.From.ID)
+				if err != nil {
+					logger.Error("Ошибка при отмене заполнения анкеты", "error", err)
+				}
+			case "edit":
+				// Обработка команды "/edit"
+				err := b.EditProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при попытке редактирования анкеты", "error", err)
+				}
+			case "delete":
+				// Обработка команды "/delete"
+				err := b.DeleteProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при попытке удаления анкеты", "error", err)
+				}
+			case "hide":
+				// Обработка команды "/hide"
+				err := b.HideProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при попытке скрытия анкеты", "error", err)
+				}
+			case "show":
+				// Обработка команды "/show"
+				err := b.ShowProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при попытке показа анкеты", "error", err)
+				}
+			case "myprofile":
+				// Обработка команды "/myprofile"
+				err := b.MyProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при показе анкеты владельцу", "error", err)
+				}
+			case "mydata":
+				// Обработка команды "/mydata"
+				err := b.MyData(ctx, update.Message.From.ID)
+				if err != nil {
+					logger.Error("Ошибка при выгрузке данных пользователя", "error", err)
+				}
+			case "forget_me":
+				// Обработка команды "/forget_me"
+				err := b.ForgetMe(ctx, update.Message.From.ID)
+				if err != nil {
+					logger.Error("Ошибка при запросе удаления данных пользователя", "error", err)
+				}
+			case "history":
+				// Обработка команды "/history"
+				err := b.History(ctx, chatID, update.Message)
+				if err != nil {
+					logger.Error("Ошибка при показе истории пользователя", "error", err)
+				}
+			case "find":
+				// Обработка команды "/find"
+				err := b.FindProfiles(ctx, update.Message.From.ID, chatID, update.Message.CommandArguments())
+				if err != nil {
+					logger.Error("Ошибка при поиске анкет", "error", err)
+				}
+			case "reconcile":
+				// Обработка команды "/reconcile"
+				err := b.Reconcile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при сверке анкет с группой", "error", err)
+				}
+			case "backup":
+				// Обработка команды "/backup"
+				err := b.Backup(ctx, update.Message)
 				if err != nil {
-					log.Printf("Ошибка при отправке информации: %v", err)
+					logger.Error("Ошибка при создании резервной копии", "error", err)
 				}
-			case "/start":
-				// Обработка команды "Создание анкеты"
-				log.Printf("Отправка сообщения пользователю с ID: %d", update.CallbackQuery.From.ID)
-				err := b.CreateProfile(update.CallbackQuery.From.ID, chatID, updates)
+			case "stats":
+				// Обработка команды "/stats"
+				err := b.Stats(ctx, chatID, update.Message)
 				if err != nil {
-					log.Printf("Ошибка при создании анкеты: %v", err)
+					logger.Error("Ошибка при отправке статистики", "error", err)
 				}
-			case "/edit":
-				// Обработка команды "Редактирование анкеты"
-				err := b.EditProfile(update.CallbackQuery.From.ID, chatID, updates)
+			case "export":
+				// Обработка команды "/export"
+				err := b.Export(ctx, chatID, update.Message)
 				if err != nil {
-					log.Printf("Ошибка при попытке редактирования анкеты: %v", err)
+					logger.Error("Ошибка при выгрузке анкет", "error", err)
 				}
-			case "/delete":
-				// Обработка команды "Удаление анкеты"
-				err := b.DeleteProfile(update.CallbackQuery.From.ID)
+			case "broadcast":
+				// Обработка команды "/broadcast"
+				err := b.Broadcast(ctx, chatID, update.Message)
 				if err != nil {
-					log.Printf("Ошибка при попытке удаления анкеты: %v", err)
+					logger.Error("Ошибка при подготовке рассылки", "error", err)
 				}
+			case "grant":
+				// Обработка команды "/grant"
+				err := b.Grant(ctx, chatID, update.Message)
+				if err != nil {
+					logger.Error("Ошибка при назначении организатора", "error", err)
+				}
+			case "revoke":
+				// Обработка команды "/revoke"
+				err := b.Revoke(ctx, chatID, update.Message)
+				if err != nil {
+					logger.Error("Ошибка при снятии роли организатора", "error", err)
+				}
+			case "up":
+				// Обработка команды "/up"
+				err := b.BumpProfile(ctx, update.Message.From.ID, chatID)
+				if err != nil {
+					logger.Error("Ошибка при попытке поднятия анкеты", "error", err)
+				}
+			default:
+				// Обработка неизвестных команд
+				err := b.sendUnknownCommandMessage(ctx, update.Message.Chat.ID)
+				if err != nil {
+					logger.Error("Ошибка при отправке сообщения с неизвестной командой", "error", err)
+				}
+			}
+		} else if update.Message.Chat.IsPrivate() && b.awaitingSearchInput(update.Message.From.ID) {
+			// Текстом вводятся условия поиска анкет
+			err := b.applySearchInput(ctx, update.Message.From.ID, update.Message.Text)
+			if err != nil {
+				logger.Error("Ошибка при поиске анкет", "error", err)
 			}
 		}
 	}
-}
 
-func (b *MotoBot) CreateProfile(userID int, chatID int64, updates <-chan tgbotapi.Update) error {
-	// Получаем профиль пользователя из хранилища
-	profile, err := b.dataStorage.GetProfile(userID)
-	if err != nil {
-		// Если профиль не найден, создаем новую анкету
-		if profile == nil {
-			profile = &user.Profile{
-				UserID: userID,
-			}
+	// Поиск анкет из любого чата через @бота
+	if update.InlineQuery != nil {
+		err := b.answerInlineQuery(ctx, update.InlineQuery)
+		if err != nil {
+			logger.Error("Ошибка при ответе на инлайн запрос", "error", err)
 		}
+	}
 
-		// Переменная состояния для отслеживания текущего шага создания анкеты
-		creationState := user.StepFirstName
-
-		for {
-			// Отправляем запрос данных в зависимости от текущего шага
-			var message tgbotapi.MessageConfig
-			switch creationState {
-			case user.StepFirstName:
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 1: Введите ваше имя:")
-			case user.StepLastName:
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 2: Введите вашу фамилию:")
-			case user.StepAge:
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 3: Введите ваш возраст:")
-			case user.StepIsDriver:
-				inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-					tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData("Да", "is_driver_yes"),
-						tgbotapi.NewInlineKeyboardButtonData("Нет", "is_driver_no"),
-					),
-				)
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 4: Вы являетесь водителем?")
-				message.ReplyMarkup = inlineKeyboard
-			case user.StepInterests:
-				var messageText string
-				if profile.IsDriver {
-					messageText = "Шаг 5: Какие у вас будут пожелания к пассажиру?"
-				} else {
-					messageText = "Шаг 5: Какие у вас будут пожелания к водителю?"
-				}
-				message = tgbotapi.NewMessage(int64(userID), messageText)
-			case user.StepPhoto:
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 6: Загрузите фотографию на ваш выбор:")
-			case user.StepContacts:
-				message = tgbotapi.NewMessage(int64(userID), "Шаг 7: Укажите по желанию контакты для связи с вами, например - номер телефона:")
-			case user.StepCompleted:
-				break
+	// Обработка текстовых команд
+	if update.CallbackQuery != nil {
+		// Получаем данные, связанные с CallbackQuery
+		callbackData := update.CallbackQuery.Data
+		chatID := b.resolveCommunity(ctx, update.CallbackQuery.From.ID, update.CallbackQuery.Message.Chat)
+		// Кнопки действий с анкетой относятся к анкете в конкретной группе
+		if action, profileChat, ok := parseProfileCallback(callbackData); ok {
+			callbackData, chatID = action, profileChat
+		}
+		if strings.HasPrefix(callbackData, "/") {
+			commandMetric(callbackData)
+		}
+		// Подтверждение удаления анкеты разрешено тем же, кому доступна команда /delete
+		command, isCommand := strings.CutPrefix(callbackData, "/")
+		if callbackData == callbackDeleteConfirm {
+			command, isCommand = "delete", true
+		}
+		if isCommand && !b.authorize(ctx, command, update.CallbackQuery.From.ID, chatID, update.CallbackQuery.Message.Chat.ID) {
+			return
+		}
+		switch callbackData {
+		case "/info":
+			// Обработка команды "Информация"
+			err := b.GetProjectInfo(ctx, update.CallbackQuery.Message.Chat.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при отправке информации", "error", err)
 			}
-
-			// Отправляем сообщение
-			_, err := b.bot.Send(message)
+		case "/start":
+			// Обработка команды "Создание анкеты"
+			err := b.CreateProfile(ctx, update.CallbackQuery.From.ID, chatID)
 			if err != nil {
-				return err
+				logger.Error("Ошибка при создании анкеты", "error", err)
 			}
-
-			// Ожидаем ответ от пользователя
-			userUpdate, ok := <-updates
-			if !ok {
-				// Канал закрыт, завершаем выполнение
-				return nil
+		case "/edit":
+			// Обработка команды "Редактирование анкеты"
+			err := b.EditProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при попытке редактирования анкеты", "error", err)
 			}
-			if userUpdate.Message != nil && (userUpdate.Message.Text != "" || len(*userUpdate.Message.Photo) > 0) {
-				switch creationState {
-				case user.StepFirstName:
-					profile.FirstName = userUpdate.Message.Text
-					creationState = user.StepLastName
-				case user.StepLastName:
-					profile.LastName = userUpdate.Message.Text
-					creationState = user.StepAge
-				case user.StepAge:
-					age, err := strconv.Atoi(userUpdate.Message.Text)
-					if err == nil {
-						profile.Age = age
-					}
-					creationState = user.StepIsDriver
-				case user.StepIsDriver:
-					if userUpdate.Message.Text == "Да" {
-						profile.IsDriver = true
-					} else {
-						profile.IsDriver = false
-					}
-					creationState = user.StepInterests
-				case user.StepInterests:
-					profile.Interests = userUpdate.Message.Text
-					creationState = user.StepPhoto
-				case user.StepPhoto:
-					// Проверяем, есть ли фотографии в сообщении
-					if userUpdate.Message != nil {
-						if userUpdate.Message.Photo != nil && len(*userUpdate.Message.Photo) > 0 {
-							// Выбираем самую большую по размеру фотографию из всех отправленных
-							largestPhoto := (*userUpdate.Message.Photo)[0]
-							for _, photo := range *userUpdate.Message.Photo {
-								if photo.FileSize > largestPhoto.FileSize {
-									largestPhoto = photo
-								}
-							}
-
-							// Получаем информацию о файле фотографии
-							fileConfig := tgbotapi.FileConfig{FileID: largestPhoto.FileID}
-							photoFile, err := b.bot.GetFile(fileConfig)
-							if err != nil {
-								log.Printf("Ошибка при получении файла фотографии: %v", err)
-								// Обработка ошибки - возможно, стоит уведомить пользователя
-								// В данном случае, можно просто отправить сообщение, что не удалось получить фотографию.
-							} else {
-								// Загружаем фотографию
-								photoBytes, err := b.downloadPhoto(photoFile.FilePath)
-								if err != nil {
-									log.Printf("Ошибка при загрузке файла фотографии: %v", err)
-								} else {
-									// Добавляем фотографию в структуру пользователя
-									profile.Photo = photoBytes
-
-									// Выводим информацию о фотографии в лог
-									log.Printf("Сохранена фотография размером %d байт", len(photoBytes))
-
-									// Переходим к следующему шагу
-									creationState = user.StepContacts
-								}
-							}
-						} else {
-							log.Println("Нет фотографий в сообщении")
-						}
-					} else {
-						// Если нет фотографий в сообщении, отправляем сообщение пользователю
-						message := tgbotapi.NewMessage(int64(userID), "На данном шаге необходимо загрузить фотографию.")
-						_, err := b.bot.Send(message)
-						if err != nil {
-							log.Printf("Ошибка при отправке сообщения: %v", err)
-							// Обработка ошибки
-						}
-					}
-				case user.StepContacts:
-					profile.Contacts = userUpdate.Message.Text
-					creationState = user.StepCompleted
-				}
-			} else if userUpdate.CallbackQuery != nil {
-				if userUpdate.CallbackQuery.Data == "is_driver_yes" {
-					profile.IsDriver = true
-				} else if userUpdate.CallbackQuery.Data == "is_driver_no" {
-					profile.IsDriver = false
-				}
-				creationState = user.StepInterests
+		case "/delete":
+			// Обработка команды "Удаление анкеты": анкета удаляется только после подтверждения
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.askDeleteProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при запросе подтверждения удаления анкеты", "error", err)
 			}
-
-			// Выход из цикла, если текущий шаг завершен
-			if creationState == user.StepCompleted {
-				break
+		case callbackDeleteConfirm:
+			// Владелец подтвердил удаление анкеты
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.DeleteProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при попытке удаления анкеты", "error", err)
+			}
+		case callbackDeleteCancel:
+			// Владелец передумал удалять анкету
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			message := tgbotapi.NewMessage(int64(update.CallbackQuery.From.ID), "Удаление отменено, анкета сохранена.")
+			b.post(ctx, message)
+		case "/hide":
+			// Обработка команды "Скрытие анкеты"
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.HideProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при попытке скрытия анкеты", "error", err)
+			}
+		case "/show":
+			// Обработка команды "Возвращение анкеты"
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.ShowProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при попытке показа анкеты", "error", err)
+			}
+		case callbackFreshConfirm:
+			// Владелец подтвердил актуальность анкеты
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.confirmProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при подтверждении актуальности анкеты", "error", err)
+			}
+		case callbackFreshHide:
+			// Владелец решил скрыть неактуальную анкету
+			b.answerCallback(ctx, update.CallbackQuery.ID)
+			err := b.HideProfile(ctx, update.CallbackQuery.From.ID, chatID)
+			if err != nil {
+				logger.Error("Ошибка при попытке скрытия анкеты", "error", err)
+			}
+		case callbackBroadcastSend, callbackBroadcastCancel:
+			// Подтверждение или отмена рассылки
+			err := b.handleBroadcastCallback(ctx, update.CallbackQuery)
+			if err != nil {
+				logger.Error("Ошибка при отправке рассылки", "error", err)
+			}
+		case callbackForgetConfirm, callbackForgetCancel:
+			// Подтверждение или отмена удаления всех данных пользователя
+			err := b.handleForgetCallback(ctx, update.CallbackQuery)
+			if err != nil {
+				logger.Error("Ошибка при удалении данных пользователя", "error", err)
+			}
+		default:
+			// Кнопки конструктора поиска анкет
+			if isFindCallback(callbackData) {
+				err := b.handleFindCallback(ctx, update.CallbackQuery, chatID)
+				if err != nil {
+					logger.Error("Ошибка при поиске анкет", "error", err)
+				}
 			}
 		}
+	}
+}
 
-		// Сохраняем профиль в хранилище
-		err = b.dataStorage.SaveProfile(profile)
-		if err != nil {
-			return err
-		}
-
-		// После завершения всех шагов, отправляем анкету в группу
-		err := b.SendProfile(userID, chatID, profile)
-		if err != nil {
-			return err
+// Редактирование анкеты
+func (b *MotoBot) EditProfile(ctx context.Context, userID int, chatID int64) error {
+	// Получите профиль пользователя из хранилища
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Если профиль не найден, отправьте сообщение пользователю
+		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
+		_, sendErr := b.send(ctx, message)
+		if sendErr != nil {
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
 		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
 
-		// Отправляем сообщение об успешном создании анкеты
-		message := tgbotapi.NewMessage(int64(userID), "Ваша анкета успешно создана и отправлена в группу.")
-		_, err = b.bot.Send(message)
-		if err != nil {
-			return err
-		}
-	} else {
-		message := tgbotapi.NewMessage(int64(userID), "Вы уже создали анкету.")
-		_, err := b.bot.Send(message)
-		if err != nil {
-			return err
-		}
+	// Изменения копятся в сессии и попадают в анкету только после публикации из предпросмотра
+	session := &user.Session{
+		UserID:   userID,
+		ChatID:   profile.ChatID,
+		Step:     user.StepPreview,
+		Furthest: user.StepPreview,
+		Review:   true,
+		Editing:  true,
+		Profile:  *profile,
+	}
+	err = b.touchSession(ctx, session)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// Отправьте инлайн клавиатуру для выбора раздела анкеты
+	return b.sendFieldChooser(ctx, session)
 }
 
-// Редактирование анкеты
-func (b *MotoBot) EditProfile(userID int, chatID int64, updates <-chan tgbotapi.Update) error {
+// Удаление анкеты
+func (b *MotoBot) DeleteProfile(ctx context.Context, userID int, chatID int64) error {
 	// Получите профиль пользователя из хранилища
-	profile, err := b.dataStorage.GetProfile(userID)
-	if err != nil {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
 		// Если профиль не найден, отправьте сообщение пользователю
 		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
-		_, sendErr := b.bot.Send(message)
+		_, sendErr := b.send(ctx, message)
 		if sendErr != nil {
-			log.Printf("Ошибка отправки сообщения: %v", sendErr)
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
 		}
 		return err
 	}
-	// Отправите инлайн клавиатуру для выбора раздела анкеты
+	if err != nil {
+		return err
+	}
 
-	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Имя", "edit_name"),
-			tgbotapi.NewInlineKeyboardButtonData("Фамилия", "edit_last_name"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Возраст", "edit_age"),
-			tgbotapi.NewInlineKeyboardButtonData("Интересы", "edit_interests"),
-		),
+	// Удалите профиль из хранилища
+	err = b.dataStorage.DeleteProfile(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	metrics.ProfileEvents.WithLabelValues(metrics.ProfileDeleted).Inc()
+	b.auditProfile(ctx, profile, userID, user.AuditDeleted, "")
+
+	// Удалите сообщение с анкетой из группы, используя MessageID
+	b.deleteProfilePost(ctx, profile)
+
+	// Отправьте сообщение об успешном удалении
+	message := tgbotapi.NewMessage(int64(userID), "Анкета успешно удалена.")
+	_, err = b.send(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// askDeleteProfile спрашивает владельца, действительно ли удалить анкету в группе.
+func (b *MotoBot) askDeleteProfile(ctx context.Context, userID int, chatID int64) error {
+	text := "Удалить анкету? Она будет удалена из группы, восстановить её будет нельзя."
+	if name := b.community(chatID).Name; name != "" {
+		text = "Удалить анкету в сообществе «" + name + "»? Она будет удалена из группы, восстановить её будет нельзя."
+	}
+	message := tgbotapi.NewMessage(int64(userID), text)
+	message.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Завершить редактирование", "finish_editing"),
+			tgbotapi.NewInlineKeyboardButtonData("Удалить", profileCallbackData(callbackDeleteConfirm, chatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", callbackDeleteCancel),
 		),
 	)
-	message := tgbotapi.NewMessage(int64(userID), "Выберите раздел анкеты для редактирования:")
-	message.ReplyMarkup = inlineKeyboard
+	_, err := b.send(ctx, message)
+	return err
+}
 
-	_, err = b.bot.Send(message)
+// HideProfile снимает анкету из группы, сохраняя её данные, чтобы позже вернуть командой /show.
+func (b *MotoBot) HideProfile(ctx context.Context, userID int, chatID int64) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
+		_, sendErr := b.send(ctx, message)
+		if sendErr != nil {
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
+		}
+		return err
+	}
 	if err != nil {
 		return err
 	}
 
-	// Ожидайте выбора пользователя
-	for {
-		userUpdate, ok := <-updates
-		if !ok {
-			// Канал закрыт, завершите выполнение
-			return nil
-		}
-
-		if userUpdate.CallbackQuery != nil {
-			callbackData := userUpdate.CallbackQuery.Data
-			switch callbackData {
-			case "edit_name":
-				// Редактирование имени
-				message := tgbotapi.NewMessage(int64(userID), "Редактирование: Введите новое имя:")
-				_, err := b.bot.Send(message)
-				if err != nil {
-					return err
-				}
-				userUpdate, ok = <-updates
-				if !ok || userUpdate.Message == nil || userUpdate.Message.Text == "" {
-					continue
-				}
-				profile.FirstName = userUpdate.Message.Text
-
-			case "edit_last_name":
-				// Редактирование фамилии
-				message := tgbotapi.NewMessage(int64(userID), "Редактирование: Введите новую фамилию:")
-				_, err := b.bot.Send(message)
-				if err != nil {
-					return err
-				}
-				userUpdate, ok = <-updates
-				if !ok || userUpdate.Message == nil || userUpdate.Message.Text == "" {
-					continue
-				}
-				profile.LastName = userUpdate.Message.Text
+	if profile.Hidden {
+		message := tgbotapi.NewMessage(int64(userID), "Анкета уже скрыта. Чтобы вернуть её в группу, отправьте /show.")
+		_, err := b.send(ctx, message)
+		return err
+	}
 
-			case "edit_age":
-				// Редактирование возраста
-				message := tgbotapi.NewMessage(int64(userID), "Редактирование: Введите новый возраст:")
-				_, err := b.bot.Send(message)
-				if err != nil {
-					return err
-				}
-				userUpdate, ok = <-updates
-				if !ok || userUpdate.Message == nil || userUpdate.Message.Text == "" {
-					continue
-				}
-				age, err := strconv.Atoi(userUpdate.Message.Text)
-				if err != nil {
-					continue
-				}
-				profile.Age = age
+	// Удалите сообщение с анкетой из группы, сама анкета остаётся в хранилище
+	b.deleteProfilePost(ctx, profile)
 
-			case "edit_interests":
-				// Редактирование интересов
-				message := tgbotapi.NewMessage(int64(userID), "Редактирование: Напишите о своих новых интересах и увлечениях:")
-				_, err := b.bot.Send(message)
-				if err != nil {
-					return err
-				}
-				userUpdate, ok = <-updates
-				if !ok || userUpdate.Message == nil || userUpdate.Message.Text == "" {
-					continue
-				}
-				profile.Interests = userUpdate.Message.Text
-
-			case "finish_editing":
-				// Удаление старой анкеты из группы, если она существует
-				if profile.MessageID != 0 {
-					deleteMessage := tgbotapi.NewDeleteMessage(b.chatID, profile.MessageID)
-					_, err = b.bot.DeleteMessage(deleteMessage)
-					if err != nil {
-						return err
-					}
-				}
+	profile.MessageID = 0
+	profile.Hidden = true
+	err = b.dataStorage.SaveProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+	b.auditProfile(ctx, profile, userID, user.AuditHidden, "")
 
-				// Отправление обновленной анкеты в группу
-				err = b.SendProfile(userID, chatID, profile)
-				if err != nil {
-					return err
-				}
+	message := tgbotapi.NewMessage(int64(userID), "Анкета скрыта из группы и не показывается в поиске. Чтобы вернуть её, отправьте /show.")
+	_, err = b.send(ctx, message)
+	return err
+}
 
-				// Завершение редактирования
-				message := tgbotapi.NewMessage(int64(userID), "Редактирование завершено.")
-				_, err := b.bot.Send(message)
-				if err != nil {
-					return err
-				}
+// ShowProfile снова публикует скрытую анкету в группе.
+func (b *MotoBot) ShowProfile(ctx context.Context, userID int, chatID int64) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
+		_, sendErr := b.send(ctx, message)
+		if sendErr != nil {
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
 
-				// Обновление профиля в хранилище
-				err = b.dataStorage.SaveProfile(profile)
-				if err != nil {
-					return err
-				}
+	if !profile.Hidden {
+		message := tgbotapi.NewMessage(int64(userID), "Анкета и так опубликована в группе.")
+		_, err := b.send(ctx, message)
+		return err
+	}
 
-				return nil
-			}
-		}
+	// Отправьте анкету в группу, вместе с новым MessageID она сохранится в хранилище.
+	// Возвращение анкеты заодно подтверждает её актуальность
+	profile.Hidden = false
+	profile.Expired = false
+	profile.ConfirmedAt = time.Now()
+	profile.AskedAt = time.Time{}
+	// Анкета возвращается в группу своего сообщества
+	err = b.SendProfile(ctx, userID, profile.ChatID, profile)
+	if err != nil {
+		return err
 	}
+	b.auditProfile(ctx, profile, userID, user.AuditShown, "")
+
+	message := tgbotapi.NewMessage(int64(userID), "Анкета снова опубликована в группе.")
+	_, err = b.send(ctx, message)
+	return err
 }
 
-// Удаление анкеты
-func (b *MotoBot) DeleteProfile(userID int) error {
-	// Получите профиль пользователя из хранилища
-	profile, err := b.dataStorage.GetProfile(userID)
-	if err != nil {
-		// Если профиль не найден, отправьте сообщение пользователю
+// MyProfile показывает владельцу его анкету в том виде, в котором она публикуется в группе,
+// вместе со статусом, ссылкой на сообщение в группе и кнопками управления.
+func (b *MotoBot) MyProfile(ctx context.Context, userID int, chatID int64) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
 		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
-		_, sendErr := b.bot.Send(message)
+		_, sendErr := b.send(ctx, message)
 		if sendErr != nil {
-			log.Printf("Ошибка отправки сообщения: %v", sendErr)
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
 		}
 		return err
 	}
+	if err != nil {
+		return err
+	}
 
-	// Удалите профиль из хранилища
-	err = b.dataStorage.DeleteProfile(userID)
+	// Скрытую анкету можно вернуть, опубликованную - скрыть
+	// Кнопки относятся к анкете в её группе, даже если у пользователя анкеты в нескольких группах
+	visibilityButton := tgbotapi.NewInlineKeyboardButtonData("Скрыть", profileCallbackData("/hide", profile.ChatID))
+	if profile.Hidden {
+		visibilityButton = tgbotapi.NewInlineKeyboardButtonData("Опубликовать", profileCallbackData("/show", profile.ChatID))
+	}
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Редактировать", profileCallbackData("/edit", profile.ChatID)),
+			visibilityButton,
+			tgbotapi.NewInlineKeyboardButtonData("Удалить", profileCallbackData("/delete", profile.ChatID)),
+		),
+	)
+
+	caption := b.profileCaption(userID, profile.ChatID, profile) + "\n" + b.profileStatus(profile)
+	_, err = b.send(ctx, profileMessage(int64(userID), caption, profile, inlineKeyboard))
+	return err
+}
+
+// profileStatus описывает, видна ли анкета в группе, и даёт ссылку на сообщение с ней.
+func (b *MotoBot) profileStatus(profile *user.Profile) string {
+	switch {
+	case profile.Expired:
+		return "Статус: снята из группы, так как актуальность не подтверждена. Вернуть: /show"
+	case profile.Hidden:
+		return "Статус: скрыта. Вернуть: /show"
+	case profile.MessageID == 0:
+		return "Статус: опубликована, но сообщение в группе не найдено."
+	}
+
+	status := "Статус: опубликована"
+	if !profile.AskedAt.IsZero() {
+		status += ", ожидает подтверждения актуальности"
+	}
+	if link := messageLink(profile.ChatID, profile.MessageID); link != "" {
+		status += "\nСообщение в группе: " + link
+	}
+	return status
+}
+
+// messageLink возвращает ссылку на сообщение в группе. Ссылки вида t.me/c/... работают только для супергрупп.
+func messageLink(chatID int64, messageID int) string {
+	id := strconv.FormatInt(chatID, 10)
+	if !strings.HasPrefix(id, "-100") {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), messageID)
+}
+
+// Отправляет анкету пользователя в группу сообщества, а если она указана, в тему группы, и сохраняет MessageID в хранилище
+func (b *MotoBot) SendProfile(ctx context.Context, userID int, chatID int64, profile *user.Profile) error {
+	// Отправьте сообщение с фотографией и текстом
+	caption := b.profileCaption(userID, chatID, profile)
+	var (
+		sentMsg tgbotapi.Message
+		err     error
+	)
+	if topicID := b.community(chatID).TopicID; topicID != 0 {
+		sentMsg, err = b.sendToTopic(ctx, chatID, topicID, caption, profile)
+	} else {
+		sentMsg, err = b.send(ctx, profileMessage(chatID, caption, profile, nil))
+	}
 	if err != nil {
 		return err
 	}
 
-	// Удалите сообщение с анкетой из группы, используя MessageID
-	if profile.MessageID != 0 {
-		deleteMessage := tgbotapi.NewDeleteMessage(b.chatID, profile.MessageID)
-		_, err = b.bot.DeleteMessage(deleteMessage)
-		if err != nil {
-			return err
-		}
+	// Сохраните группу и MessageID в профиле анкеты
+	profile.ChatID = chatID
+	profile.MessageID = sentMsg.MessageID
+	if sentMsg.Photo != nil && len(*sentMsg.Photo) > 0 {
+		profile.PhotoFileID = largestPhoto(*sentMsg.Photo).FileID
 	}
 
-	// Отправьте сообщение об успешном удалении
-	message := tgbotapi.NewMessage(int64(userID), "Анкета успешно удалена.")
-	_, err = b.bot.Send(message)
+	// Обновите профиль в хранилище с новым MessageID
+	err = b.dataStorage.SaveProfile(ctx, profile)
 	if err != nil {
 		return err
 	}
@@ -512,16 +831,117 @@ func (b *MotoBot) DeleteProfile(userID int) error {
 	return nil
 }
 
-// Отправляет анкету пользователя в группу и сохраняет MessageID в хранилище
-func (b *MotoBot) SendProfile(userID int, chatID int64, profile *user.Profile) error {
+// updateProfilePost изменяет сообщение с анкетой в группе profile.ChatID на месте, чтобы сохранить
+// ответы на него, ссылки и порядок сообщений. Если изменить сообщение нельзя, например анкета была
+// без фотографии, а теперь с ней, или сообщение пропало, старое сообщение удаляется и анкета
+// отправляется заново. Остальные ошибки, например превышение лимита запросов, возвращаются как есть,
+// чтобы временный сбой не превращал правку в повторную публикацию.
+func (b *MotoBot) updateProfilePost(ctx context.Context, published, profile *user.Profile) error {
+	chatID := profile.ChatID
+	if profile.MessageID == 0 {
+		return b.SendProfile(ctx, profile.UserID, chatID, profile)
+	}
+
+	caption := b.profileCaption(profile.UserID, chatID, profile)
+	hadPhoto := len(published.Photo) > 0
+	hasPhoto := len(profile.Photo) > 0
+
+	var err error
+	switch {
+	case hadPhoto && hasPhoto && profile.PhotoFileID == published.PhotoFileID:
+		_, err = b.send(ctx, tgbotapi.NewEditMessageCaption(chatID, profile.MessageID, caption))
+	case hadPhoto && hasPhoto && profile.PhotoFileID != "":
+		err = b.editMessagePhoto(ctx, chatID, profile.MessageID, profile.PhotoFileID, caption)
+	case !hadPhoto && !hasPhoto:
+		_, err = b.send(ctx, tgbotapi.NewEditMessageText(chatID, profile.MessageID, caption))
+	default:
+		// Текстовое сообщение нельзя превратить в фотографию и наоборот
+		err = errMessageNotEditable
+	}
+	if isNotModified(err) {
+		err = nil
+	}
+	if err == nil {
+		return b.dataStorage.SaveProfile(ctx, profile)
+	}
+	if !isMessageNotEditable(err) && !isMessageNotFound(err) {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Не удалось изменить анкету в группе на месте, отправляем заново",
+		"error", err, "message_id", profile.MessageID)
+
+	// Сообщения старше 48 часов бот удалить не может, тогда в группе останется и старая анкета
+	err = b.deleteMessage(ctx, chatID, profile.MessageID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Ошибка при удалении старой анкеты из группы", "error", err, "message_id", profile.MessageID)
+	}
+	return b.SendProfile(ctx, profile.UserID, chatID, profile)
+}
+
+// deleteProfilePost удаляет сообщение с анкетой из группы. Сообщения старше 48 часов бот удалить
+// не может, поэтому ошибка только записывается в лог, а анкета всё равно считается снятой.
+func (b *MotoBot) deleteProfilePost(ctx context.Context, profile *user.Profile) {
+	if profile.MessageID == 0 {
+		return
+	}
+	err := b.deleteMessage(ctx, profile.ChatID, profile.MessageID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Ошибка при удалении анкеты из группы", "error", err,
+			"user_id", profile.UserID, "message_id", profile.MessageID)
+	}
+}
+
+// editMessagePhoto заменяет фотографию и подпись сообщения. В используемой версии библиотеки
+// нет метода editMessageMedia, поэтому запрос формируется вручную.
+func (b *MotoBot) editMessagePhoto(ctx context.Context, chatID int64, messageID int, fileID, caption string) error {
+	media, err := json.Marshal(map[string]string{
+		"type":    "photo",
+		"media":   fileID,
+		"caption": caption,
+	})
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Add("chat_id", strconv.FormatInt(chatID, 10))
+	params.Add("message_id", strconv.Itoa(messageID))
+	params.Add("media", string(media))
+
+	_, err = b.request(ctx, "editMessageMedia", params)
+	return err
+}
+
+// isNotModified сообщает, что Telegram отказался менять сообщение, так как его содержимое не изменилось.
+func isNotModified(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "message is not modified")
+}
+
+// isForbidden сообщает, что Telegram не даёт писать пользователю, например он заблокировал бота.
+func isForbidden(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Forbidden")
+}
+
+// isMessageNotEditable сообщает, что сообщение с анкетой нельзя изменить на месте, и его нужно отправить заново.
+func isMessageNotEditable(err error) bool {
+	return errors.Is(err, errMessageNotEditable) ||
+		(err != nil && strings.Contains(err.Error(), "message can't be edited"))
+}
+
+// profileCaption формирует текст анкеты в том виде, в котором она публикуется в группе.
+func (b *MotoBot) profileCaption(userID int, chatID int64, profile *user.Profile) string {
 	// Извлеките username из полученной информации, если он доступен
-	username, err := b.getUsername(userID, chatID)
+	username, _ := b.getUsername(userID, chatID)
 
 	// Подготовьте текст анкеты
 	messageText := "Анкета пользователя: " + "@" + username + "\n"
 	messageText += "Имя: " + profile.FirstName + "\n"
 	messageText += "Фамилия: " + profile.LastName + "\n"
 	messageText += "Возраст: " + strconv.Itoa(profile.Age) + "\n"
+	if profile.City != "" {
+		messageText += "Город: " + profile.City + "\n"
+	}
 	messageText += "Интересы: " + profile.Interests + "\n"
 	messageText += "Водитель: "
 	if profile.IsDriver {
@@ -529,47 +949,53 @@ func (b *MotoBot) SendProfile(userID int, chatID int64, profile *user.Profile) e
 	} else {
 		messageText += "🚶\n"
 	}
-	messageText += "Контакты: " + profile.Contacts + "\n"
+	if profile.Contacts != "" {
+		messageText += "Контакты: " + profile.Contacts + "\n"
+	}
 
-	// Отправьте сообщение с фотографией и текстом
-	msg := tgbotapi.NewPhotoUpload(b.chatID, tgbotapi.FileBytes{
-		Bytes: profile.Photo,
-	})
-	msg.Caption = messageText
+	return messageText
+}
 
-	sentMsg, err := b.bot.Send(msg)
-	if err != nil {
-		return err
+// profileMessage готовит сообщение с анкетой: фотографию с подписью или, если фотографию пропустили, текст.
+func profileMessage(chatID int64, caption string, profile *user.Profile, replyMarkup interface{}) tgbotapi.Chattable {
+	if len(profile.Photo) > 0 {
+		photoMsg := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
+			Bytes: profile.Photo,
+		})
+		photoMsg.Caption = caption
+		photoMsg.ReplyMarkup = replyMarkup
+		return photoMsg
 	}
 
-	// Сохраните MessageID в профиле анкеты
-	profile.MessageID = sentMsg.MessageID
+	msg := tgbotapi.NewMessage(chatID, caption)
+	msg.ReplyMarkup = replyMarkup
+	return msg
+}
 
-	// Обновите профиль в хранилище с новым MessageID
-	err = b.dataStorage.SaveProfile(profile)
-	if err != nil {
+// GetProjectInfo отправляет информацию пользователю. Сообщество может заменить текст своим.
+func (b *MotoBot) GetProjectInfo(ctx context.Context, chatID int64, communityID int64) error {
+	if infoText := b.community(communityID).InfoText; infoText != "" {
+		_, err := b.send(ctx, tgbotapi.NewMessage(chatID, infoText))
 		return err
 	}
 
-	return nil
-}
-
-// GetProjectInfo отправляет информацию пользователю.
-func (b *MotoBot) GetProjectInfo(chatID int64) error {
 	// Ваш код для отправки информации
 	informationMessage := "Ебэрис Гузеев представляет новый проект мото-покатушек и знакомств «Давай прокатимся». Мальчики катают девочек, девочки катают мальчиков… Все просто) Организовываем массовые покатушки с моим участием, в которых я буду в качестве ператора и свахи)."
 	message := tgbotapi.NewMessage(chatID, informationMessage)
-	_, err := b.bot.Send(message)
+	_, err := b.send(ctx, message)
 	return err
 }
 
 // welcomeNewUser отправляет приветственное сообщение в личку пользователю и, если невозможно, то приветствует его в группе без инлайн клавиатуры.
-func (b *MotoBot) welcomeNewUser(userID int, chatID int64) error {
+func (b *MotoBot) welcomeNewUser(ctx context.Context, userID int, chatID int64) error {
 
 	username, err := b.getUsername(userID, chatID)
 
 	// Приветственное сообщение
 	welcomeMessage := fmt.Sprintf("Добро пожаловать, @%s! Чем я могу вам помочь?", username)
+	if welcomeText := b.community(chatID).WelcomeText; welcomeText != "" {
+		welcomeMessage = fmt.Sprintf("Добро пожаловать, @%s! %s", username, welcomeText)
+	}
 	welcomeMessageGroupe := fmt.Sprintf("Добро пожаловать, @%s! Для создания анкеты и получения информации - напиши мне!", username)
 
 	// Создание инлайн клавиатуры
@@ -591,21 +1017,18 @@ func (b *MotoBot) welcomeNewUser(userID int, chatID int64) error {
 	// Попытка отправить сообщение в личку
 	message := tgbotapi.NewMessage(int64(userID), welcomeMessage)
 	message.ReplyMarkup = inlineKeyboard
-	_, err = b.bot.Send(message)
+	_, err = b.send(ctx, message)
 	if err != nil {
 		// Если не удалось отправить в личку, отправляем только приветствие в группу
 		message = tgbotapi.NewMessage(chatID, welcomeMessageGroupe)
-		_, err = b.bot.Send(message)
-		if err != nil {
-			log.Printf("Ошибка при отправке приветственного сообщения: %v", err)
-		}
+		b.post(ctx, message)
 	}
 
 	return nil
 }
 
 // sendUnknownCommandMessage отправляет сообщение о неизвестной команде и инлайн клавиатуру приветствия.
-func (b *MotoBot) sendUnknownCommandMessage(chatID int64) error {
+func (b *MotoBot) sendUnknownCommandMessage(ctx context.Context, chatID int64) error {
 	// Приветственное сообщение
 	welcomeMessage := "Ваша команда не опознана, выберите что вы хотите сделать:"
 
@@ -629,14 +1052,43 @@ func (b *MotoBot) sendUnknownCommandMessage(chatID int64) error {
 	message := tgbotapi.NewMessage(chatID, welcomeMessage)
 	message.ReplyMarkup = inlineKeyboard
 
-	_, err := b.bot.Send(message)
+	_, err := b.send(ctx, message)
 	return err
 }
 
+// largestPhoto выбирает самую большую по размеру фотографию из всех отправленных.
+func largestPhoto(photos []tgbotapi.PhotoSize) tgbotapi.PhotoSize {
+	largest := photos[0]
+	for _, photo := range photos {
+		if photo.FileSize > largest.FileSize {
+			largest = photo
+		}
+	}
+	return largest
+}
+
+// downloadLargestPhoto выбирает самую большую по размеру фотографию из всех отправленных и загружает её.
+func (b *MotoBot) downloadLargestPhoto(ctx context.Context, photos []tgbotapi.PhotoSize) ([]byte, error) {
+	// Получаем информацию о файле фотографии
+	fileConfig := tgbotapi.FileConfig{FileID: largestPhoto(photos).FileID}
+	start := time.Now()
+	photoFile, err := b.bot.GetFile(fileConfig)
+	metrics.ObserveTelegram("getFile", start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.downloadPhoto(ctx, photoFile.FilePath)
+}
+
 // DownloadFile загружает файл по его пути и возвращает []byte с содержимым файла.
-func (b *MotoBot) downloadPhoto(filePath string) ([]byte, error) {
+func (b *MotoBot) downloadPhoto(ctx context.Context, filePath string) ([]byte, error) {
 	fileURL := "https://api.telegram.org/file/bot" + b.token + "/" + filePath
-	response, err := http.Get(fileURL)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -662,7 +1114,9 @@ func (b *MotoBot) getUsername(userID int, chatID int64) (string, error) {
 		UserID: userID, // ID пользователя, членство которого вы хотите проверить
 	}
 
+	start := time.Now()
 	user, err := b.bot.GetChatMember(chatConfig)
+	metrics.ObserveTelegram("getChatMember", start, err)
 	if err != nil {
 		// Обработка ошибки
 		return "", err
@@ -670,3 +1124,66 @@ func (b *MotoBot) getUsername(userID int, chatID int64) (string, error) {
 
 	return user.User.UserName, nil
 }
+
+// shutdown прекращает получение обновлений и дожидается отправки всех сообщений из очереди.
+func (b *MotoBot) shutdown() {
+	b.bot.StopReceivingUpdates()
+	b.background.Wait()
+	b.outbox.close()
+	b.logger.Info("Очередь исходящих сообщений отправлена, бот остановлен")
+}
+
+// updateLogger дополняет логгер идентификаторами обновления, пользователя, чата и командой.
+func updateLogger(logger *slog.Logger, update tgbotapi.Update) *slog.Logger {
+	attrs := []any{slog.Int("update_id", update.UpdateID)}
+
+	switch {
+	case update.Message != nil:
+		if update.Message.From != nil {
+			attrs = append(attrs, slog.Int("user_id", update.Message.From.ID))
+		}
+		if update.Message.Chat != nil {
+			attrs = append(attrs, slog.Int64("chat_id", update.Message.Chat.ID))
+		}
+		if update.Message.IsCommand() {
+			attrs = append(attrs, slog.String("command", update.Message.Command()))
+		}
+	case update.CallbackQuery != nil:
+		attrs = append(attrs, slog.Int("user_id", update.CallbackQuery.From.ID))
+		if update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil {
+			attrs = append(attrs, slog.Int64("chat_id", update.CallbackQuery.Message.Chat.ID))
+		}
+		attrs = append(attrs, slog.String("command", update.CallbackQuery.Data))
+	case update.InlineQuery != nil:
+		attrs = append(attrs, slog.Int("user_id", update.InlineQuery.From.ID))
+	}
+
+	return logger.With(attrs...)
+}
+
+// updateType возвращает тип обновления для метрик.
+func updateType(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil && update.Message.NewChatMembers != nil:
+		return "new_chat_members"
+	case update.Message != nil && update.Message.IsCommand():
+		return "command"
+	case update.Message != nil:
+		return "message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	default:
+		return "other"
+	}
+}
+
+// commandMetric учитывает вызов команды. Неизвестные команды учитываются вместе, чтобы не плодить метки.
+func commandMetric(command string) {
+	command = strings.TrimPrefix(command, "/")
+	if _, ok := commandRoles[command]; !ok {
+		command = "unknown"
+	}
+	metrics.CommandsInvoked.WithLabelValues(command).Inc()
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// broadcastInterval - пауза между сообщениями рассылки, чтобы не превысить ограничения Telegram
+const broadcastInterval = 50 * time.Millisecond
+
+// Данные инлайн кнопок подтверждения рассылки
+const (
+	callbackBroadcastSend   = "broadcast_send"
+	callbackBroadcastCancel = "broadcast_cancel"
+)
+
+// broadcast - рассылка, ожидающая подтверждения
+type broadcast struct {
+	ChatID     int64         // Сообщество, владельцам анкет которого отправляется рассылка
+	Filter     profileFilter // Роль и город получателей
+	ActiveOnly bool          // Только владельцам опубликованных анкет
+	Text       string        // Текст рассылки
+}
+
+// broadcastReport - итог рассылки
+type broadcastReport struct {
+	Delivered int // Доставлено
+	Blocked   int // Пользователь заблокировал бота
+	Failed    int // Другие ошибки
+}
+
+// broadcastFilterPrefixes - начало первой строки рассылки с условиями отбора получателей
+var broadcastFilterPrefixes = []string{"кому:", "to:"}
+
+// parseBroadcast разбирает аргументы команды /broadcast. Если первая строка начинается с «кому:»,
+// в ней перечислены условия отбора получателей, например "кому: водитель город:москва активные",
+// а текст рассылки идёт со второй строки. Иначе все аргументы - текст рассылки для всех владельцев анкет.
+// Неизвестное условие - ошибка, чтобы опечатка не превратила рассылку в рассылку всем.
+func parseBroadcast(args string) (profileFilter, bool, string, error) {
+	var filter profileFilter
+	args = strings.TrimSpace(args)
+
+	header, text, _ := strings.Cut(args, "\n")
+	conditions, ok := cutAnyPrefix(strings.ToLower(strings.TrimSpace(header)), broadcastFilterPrefixes...)
+	if !ok {
+		return filter, false, args, nil
+	}
+
+	activeOnly := false
+	for _, word := range strings.Fields(conditions) {
+		switch word {
+		case "active", "активные":
+			activeOnly = true
+		case "driver", "водитель", "водители":
+			driver := true
+			filter.Driver = &driver
+		case "passenger", "пассажир", "пассажиры":
+			driver := false
+			filter.Driver = &driver
+		default:
+			city, ok := cutAnyPrefix(word, "город:", "city:")
+			if !ok || city == "" {
+				return filter, false, "", fmt.Errorf("неизвестное условие %q", word)
+			}
+			filter.City = city
+		}
+	}
+	return filter, activeOnly, strings.TrimSpace(text), nil
+}
+
+// audience возвращает анкеты получателей рассылки.
+func (b *MotoBot) audience(ctx context.Context, pending *broadcast) ([]*user.Profile, error) {
+	filter := pending.Filter
+	filter.ChatID = pending.ChatID
+	if pending.ActiveOnly {
+		hidden := false
+		filter.Hidden = &hidden
+	}
+
+	page, err := b.dataStorage.QueryProfiles(ctx, filter.ProfileQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipients []*user.Profile
+	for _, profile := range page.Profiles {
+		if filter.match(profile) {
+			recipients = append(recipients, profile)
+		}
+	}
+	return recipients, nil
+}
+
+// Broadcast готовит рассылку владельцам анкет сообщества и показывает её для подтверждения.
+func (b *MotoBot) Broadcast(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	userID := int64(message.From.ID)
+
+	const usage = "Чтобы выбрать получателей, начните первую строку с «кому:» и укажите условия, а текст - со второй строки:\n" +
+		"/broadcast кому: водитель город:москва активные\nТекст объявления\n" +
+		"Условия: водитель, пассажир, город:<название>, активные."
+
+	filter, activeOnly, text, err := parseBroadcast(message.CommandArguments())
+	if err != nil {
+		reply := tgbotapi.NewMessage(userID, fmt.Sprintf("Не удалось разобрать получателей рассылки: %s.\n%s", err, usage))
+		_, err := b.send(ctx, reply)
+		return err
+	}
+	if text == "" {
+		reply := tgbotapi.NewMessage(userID, "Укажите текст рассылки: /broadcast Текст объявления.\n"+usage)
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	pending := &broadcast{ChatID: chatID, Filter: filter, ActiveOnly: activeOnly, Text: text}
+	recipients, err := b.audience(ctx, pending)
+	if err != nil {
+		return err
+	}
+	b.broadcasts[message.From.ID] = pending
+
+	audience := filter.describe()
+	if activeOnly {
+		audience += "; только опубликованные анкеты"
+	}
+	preview := tgbotapi.NewMessage(userID, fmt.Sprintf("Получатели: %s\nКоличество: %d\n\n%s", audience, len(recipients), text))
+	preview.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отправить", callbackBroadcastSend),
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", callbackBroadcastCancel),
+		),
+	)
+	_, err = b.send(ctx, preview)
+	return err
+}
+
+// handleBroadcastCallback отправляет подтверждённую рассылку или отменяет её.
+func (b *MotoBot) handleBroadcastCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	b.answerCallback(ctx, query.ID)
+
+	userID := query.From.ID
+	pending, ok := b.broadcasts[userID]
+	if !ok {
+		message := tgbotapi.NewMessage(int64(userID), "Рассылка не найдена. Подготовьте её заново командой /broadcast.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+	delete(b.broadcasts, userID)
+
+	if query.Data == callbackBroadcastCancel {
+		message := tgbotapi.NewMessage(int64(userID), "Рассылка отменена.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	// Права могли отозвать, пока рассылка ждала подтверждения
+	if !b.authorize(ctx, "broadcast", userID, pending.ChatID, int64(userID)) {
+		return nil
+	}
+
+	recipients, err := b.audience(ctx, pending)
+	if err != nil {
+		return err
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), fmt.Sprintf("Рассылка запущена, получателей: %d.", len(recipients)))
+	_, err = b.send(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	// Рассылка может идти долго, поэтому не задерживает обработку других обновлений
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		b.runBroadcast(ctx, userID, pending.Text, recipients)
+	}()
+	return nil
+}
+
+// runBroadcast отправляет рассылку с паузами между сообщениями и сообщает автору итог.
+func (b *MotoBot) runBroadcast(ctx context.Context, authorID int, text string, recipients []*user.Profile) {
+	logger := logging.FromContext(ctx)
+	var report broadcastReport
+
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+
+	interrupted := false
+	for _, recipient := range recipients {
+		select {
+		case <-ctx.Done():
+			interrupted = true
+		case <-ticker.C:
+		}
+		if interrupted {
+			break
+		}
+
+		_, err := b.send(ctx, tgbotapi.NewMessage(int64(recipient.UserID), text))
+		switch {
+		case err == nil:
+			report.Delivered++
+		case isForbidden(err):
+			report.Blocked++
+		default:
+			report.Failed++
+			logger.Warn("Ошибка при отправке рассылки", "error", err, "recipient_id", recipient.UserID)
+		}
+	}
+
+	logger.Info("Рассылка завершена", "delivered", report.Delivered, "blocked", report.Blocked,
+		"failed", report.Failed, "interrupted", interrupted)
+
+	summary := fmt.Sprintf("Рассылка завершена.\nДоставлено: %d\nБот заблокирован: %d\nОшибок: %d",
+		report.Delivered, report.Blocked, report.Failed)
+	if interrupted {
+		summary = fmt.Sprintf("Рассылка прервана остановкой бота.\nДоставлено: %d\nБот заблокирован: %d\nОшибок: %d\nНе отправлено: %d",
+			report.Delivered, report.Blocked, report.Failed, len(recipients)-report.Delivered-report.Blocked-report.Failed)
+	}
+
+	// Итог отправляется и при остановке бота, очередь исходящих сообщений дождётся его
+	_, err := b.send(context.WithoutCancel(ctx), tgbotapi.NewMessage(int64(authorID), summary))
+	if err != nil {
+		logger.Error("Ошибка при отправке итога рассылки", "error", err)
+	}
+}
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// Варианты анкеты сообщества
+const (
+	QuestionnaireFull  = "full"  // Все вопросы анкеты
+	QuestionnaireShort = "short" // Без необязательных вопросов: города, фотографии и контактов
+)
+
+// Community - группа, которую обслуживает бот, со своими настройками
+type Community struct {
+	ChatID        int64  // Идентификатор группы
+	Name          string // Название сообщества, например город
+	TopicID       int    // Тема группы, в которую публикуются анкеты. 0 - общий чат
+	WelcomeText   string // Приветствие новых участников в личных сообщениях. Пустое - стандартное
+	InfoText      string // Текст команды /info. Пустой - стандартный
+	Admins        []int  // Администраторы бота в сообществе помимо администраторов группы
+	Questionnaire string // Вариант анкеты: QuestionnaireFull или QuestionnaireShort
+}
+
+// asksStep сообщает, задаётся ли вопрос шага в анкете сообщества.
+func (c *Community) asksStep(step int) bool {
+	return c.Questionnaire != QuestionnaireShort || !user.IsOptionalStep(step)
+}
+
+// community возвращает настройки группы. Для неизвестной группы возвращается первое сообщество из конфигурации.
+func (b *MotoBot) community(chatID int64) *Community {
+	if community, ok := b.communities[chatID]; ok {
+		return community
+	}
+	return b.defaultCommunity
+}
+
+// isCommunity сообщает, обслуживает ли бот группу.
+func (b *MotoBot) isCommunity(chatID int64) bool {
+	_, ok := b.communities[chatID]
+	return ok
+}
+
+// profileCallbacks - инлайн кнопки, которые относятся к анкете в конкретной группе.
+// К их данным через двоеточие добавляется группа анкеты
+var profileCallbacks = map[string]bool{
+	"/edit":               true,
+	"/hide":               true,
+	"/show":               true,
+	"/delete":             true,
+	callbackDeleteConfirm: true,
+	callbackFreshConfirm:  true,
+	callbackFreshHide:     true,
+}
+
+// profileCallbackData возвращает данные кнопки действия с анкетой в группе.
+func profileCallbackData(action string, chatID int64) string {
+	return action + ":" + strconv.FormatInt(chatID, 10)
+}
+
+// parseProfileCallback разбирает данные кнопки действия с анкетой в группе.
+func parseProfileCallback(data string) (string, int64, bool) {
+	action, chat, ok := strings.Cut(data, ":")
+	if !ok || !profileCallbacks[action] {
+		return "", 0, false
+	}
+	chatID, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return action, chatID, true
+}
+
+// resolveCommunity определяет сообщество пользователя: группу, в которой пришло обновление,
+// последнюю группу, в которую он вступил или в которой отправил команду, группу одной из его анкет
+// или первую группу, участником которой он является. Так команды в личных сообщениях относятся
+// к группе, с которой пользователь работал последней.
+func (b *MotoBot) resolveCommunity(ctx context.Context, userID int, chat *tgbotapi.Chat) int64 {
+	if chat != nil && b.isCommunity(chat.ID) {
+		b.lastCommunity[userID] = chat.ID
+		return chat.ID
+	}
+	if chatID, ok := b.lastCommunity[userID]; ok {
+		return chatID
+	}
+
+	page, err := b.dataStorage.QueryProfiles(ctx, storage.ProfileQuery{UserID: userID})
+	if err != nil {
+		logging.FromContext(ctx).Error("Ошибка при получении анкет пользователя", "error", err)
+	}
+	for _, chatID := range b.communityOrder {
+		for _, profile := range page.Profiles {
+			if profile.ChatID == chatID {
+				return chatID
+			}
+		}
+	}
+
+	if len(b.communityOrder) > 1 {
+		for _, chatID := range b.communityOrder {
+			if b.isMember(ctx, chatID, userID) {
+				b.lastCommunity[userID] = chatID
+				return chatID
+			}
+		}
+	}
+	return b.defaultCommunity.ChatID
+}
+
+// isMember проверяет через Telegram, состоит ли пользователь в группе сообщества.
+func (b *MotoBot) isMember(ctx context.Context, chatID int64, userID int) bool {
+	start := time.Now()
+	member, err := b.bot.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID})
+	metrics.ObserveTelegram("getChatMember", start, err)
+	if err != nil {
+		logging.FromContext(ctx).Debug("Не удалось проверить участие в группе", "error", err, "chat_id", chatID)
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator() || member.IsMember()
+}
+
+// sendToTopic публикует анкету в теме группы. В используемой версии библиотеки нельзя указать
+// тему сообщения, поэтому запрос формируется вручную. Фотография отправляется по идентификатору
+// уже загруженного в Telegram файла.
+func (b *MotoBot) sendToTopic(ctx context.Context, chatID int64, topicID int, caption string, profile *user.Profile) (tgbotapi.Message, error) {
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("message_thread_id", strconv.Itoa(topicID))
+
+	method := "sendMessage"
+	if len(profile.Photo) > 0 {
+		if profile.PhotoFileID == "" {
+			return tgbotapi.Message{}, errors.New("у фотографии анкеты нет идентификатора файла")
+		}
+		method = "sendPhoto"
+		params.Set("photo", profile.PhotoFileID)
+		params.Set("caption", caption)
+	} else {
+		params.Set("text", caption)
+	}
+
+	resp, err := b.request(ctx, method, params)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var message tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &message)
+	return message, err
+}
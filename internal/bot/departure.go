@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// Политики обработки анкет участников, покинувших группу
+const (
+	LeaveDelete = "delete" // Удалить анкету вместе с сообщением в группе
+	LeaveHide   = "hide"   // Снять анкету из группы, сохранив её данные
+	LeaveKeep   = "keep"   // Ничего не делать
+)
+
+// departureCheckInterval - как часто проверяются анкеты участников, покинувших группу
+const departureCheckInterval = time.Hour
+
+// memberLeft отмечает, что участник вышел или был удалён из группы. Анкета обрабатывается
+// согласно политике после льготного периода, чтобы вернувшийся участник сохранил анкету.
+func (b *MotoBot) memberLeft(ctx context.Context, chatID int64, userID int) error {
+	if b.lastCommunity[userID] == chatID {
+		delete(b.lastCommunity, userID)
+	}
+
+	if b.settings.LeavePolicy == LeaveKeep {
+		return nil
+	}
+
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		// У участника нет анкеты в этой группе, убирать нечего
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	profile.LeftAt = time.Now()
+	if b.settings.LeaveGracePeriod <= 0 {
+		return b.applyLeavePolicy(ctx, profile)
+	}
+	return b.dataStorage.SaveProfile(ctx, profile)
+}
+
+// memberReturned снимает отметку о выходе из группы с анкеты вернувшегося участника.
+func (b *MotoBot) memberReturned(ctx context.Context, chatID int64, userID int) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if profile.LeftAt.IsZero() {
+		return nil
+	}
+
+	profile.LeftAt = time.Time{}
+	return b.dataStorage.SaveProfile(ctx, profile)
+}
+
+// checkDepartures применяет политику к анкетам участников, которые не вернулись в группу за льготный период.
+func (b *MotoBot) checkDepartures(ctx context.Context) {
+	if b.settings.LeavePolicy == LeaveKeep {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+
+	profiles, err := b.dataStorage.ListProfiles(ctx)
+	if err != nil {
+		logger.Error("Ошибка при получении анкет", "error", err)
+		return
+	}
+
+	for _, profile := range profiles {
+		// Скрытые анкеты уже убраны из группы, в том числе при выходе участника
+		if profile.Hidden && b.settings.LeavePolicy == LeaveHide {
+			continue
+		}
+		if profile.LeftAt.IsZero() || time.Since(profile.LeftAt) < b.settings.LeaveGracePeriod {
+			continue
+		}
+		err = b.applyLeavePolicy(ctx, profile)
+		if err != nil {
+			logger.Error("Ошибка при обработке анкеты покинувшего группу участника", "error", err, "user_id", profile.UserID)
+		}
+	}
+}
+
+// applyLeavePolicy удаляет или скрывает анкету участника, покинувшего группу.
+func (b *MotoBot) applyLeavePolicy(ctx context.Context, profile *user.Profile) error {
+	b.deleteProfilePost(ctx, profile)
+
+	logging.FromContext(ctx).Info("Анкета покинувшего группу участника убрана", "user_id", profile.UserID, "policy", b.settings.LeavePolicy)
+
+	if b.settings.LeavePolicy == LeaveDelete {
+		err := b.dataStorage.DeleteProfile(ctx, profile.ChatID, profile.UserID)
+		if err != nil {
+			return err
+		}
+		metrics.ProfileEvents.WithLabelValues(metrics.ProfileDeleted).Inc()
+		b.auditProfile(ctx, profile, 0, user.AuditDeleted, "участник покинул группу")
+		return nil
+	}
+
+	// Отметка о выходе остаётся, чтобы анкета не обрабатывалась повторно до возвращения участника
+	profile.MessageID = 0
+	profile.Hidden = true
+	err := b.dataStorage.SaveProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+	b.auditProfile(ctx, profile, 0, user.AuditHidden, "участник покинул группу")
+	return nil
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// Форматы выгрузки анкет
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportPageSize - сколько анкет читается из хранилища за один запрос при выгрузке
+const exportPageSize = 100
+
+// exportField - поле анкеты в выгрузке
+type exportField struct {
+	name  string
+	value func(profile *user.Profile) string
+}
+
+// exportFields - поля анкеты, которые можно выгрузить, в порядке столбцов по умолчанию
+var exportFields = []exportField{
+	{"user_id", func(p *user.Profile) string { return strconv.Itoa(p.UserID) }},
+	{"first_name", func(p *user.Profile) string { return p.FirstName }},
+	{"last_name", func(p *user.Profile) string { return p.LastName }},
+	{"age", func(p *user.Profile) string { return strconv.Itoa(p.Age) }},
+	{"city", func(p *user.Profile) string { return p.City }},
+	{"driver", func(p *user.Profile) string { return strconv.FormatBool(p.IsDriver) }},
+	{"interests", func(p *user.Profile) string { return p.Interests }},
+	{"contacts", func(p *user.Profile) string { return p.Contacts }},
+	{"hidden", func(p *user.Profile) string { return strconv.FormatBool(p.Hidden) }},
+	{"created_at", func(p *user.Profile) string { return formatExportTime(p.CreatedAt) }},
+	{"updated_at", func(p *user.Profile) string { return formatExportTime(p.UpdatedAt) }},
+}
+
+// formatExportTime выгружает время в формате RFC 3339, незаполненное время - пустой строкой.
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// exportRequest - параметры выгрузки из аргументов команды /export
+type exportRequest struct {
+	format string
+	fields []exportField
+	photos bool
+	all    bool // Выгружать и скрытые анкеты, в том числе снятые за неактуальностью
+}
+
+// parseExport разбирает аргументы команды /export, например "json first_name age photos".
+// Скрытые анкеты выгружаются, только если указано "all" или "все".
+func parseExport(args string) (exportRequest, error) {
+	request := exportRequest{format: exportCSV}
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		switch word {
+		case exportCSV, exportJSON:
+			request.format = word
+			continue
+		case "photos", "фото":
+			request.photos = true
+			continue
+		case "all", "все":
+			request.all = true
+			continue
+		}
+
+		field, ok := findExportField(word)
+		if !ok {
+			return request, fmt.Errorf("неизвестное поле %q", word)
+		}
+		request.fields = append(request.fields, field)
+	}
+
+	if len(request.fields) == 0 {
+		request.fields = exportFields
+	}
+	return request, nil
+}
+
+// findExportField ищет поле выгрузки по названию.
+func findExportField(name string) (exportField, bool) {
+	for _, field := range exportFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return exportField{}, false
+}
+
+// exportNames перечисляет названия полей выгрузки для подсказки.
+func exportNames() string {
+	names := make([]string, 0, len(exportFields))
+	for _, field := range exportFields {
+		names = append(names, field.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// encodeCSV выгружает анкеты в CSV с заголовком из названий полей.
+func encodeCSV(profiles []*user.Profile, fields []exportField) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := make([]string, 0, len(fields))
+	for _, field := range fields {
+		header = append(header, field.name)
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		record := make([]string, 0, len(fields))
+		for _, field := range fields {
+			record = append(record, csvCell(field.value(profile)))
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// csvCell экранирует значение ячейки CSV. Табличные редакторы считают формулой ячейку, которая начинается
+// с =, +, - или @ (в том числе после табуляции или возврата каретки), поэтому перед таким значением
+// ставится апостроф, и оно показывается как текст.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// encodeJSON выгружает анкеты в JSON массивом объектов с выбранными полями.
+func encodeJSON(profiles []*user.Profile, fields []exportField) ([]byte, error) {
+	records := make([]map[string]string, 0, len(profiles))
+	for _, profile := range profiles {
+		record := make(map[string]string, len(fields))
+		for _, field := range fields {
+			record[field.name] = field.value(profile)
+		}
+		records = append(records, record)
+	}
+	return json.MarshalIndent(records, "", "  ")
+}
+
+// zipPhotos упаковывает фотографии анкет в ZIP архив, файлы называются по идентификатору пользователя.
+func zipPhotos(profiles []*user.Profile) ([]byte, int, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	count := 0
+	for _, profile := range profiles {
+		if len(profile.Photo) == 0 {
+			continue
+		}
+		file, err := archive.Create(strconv.Itoa(profile.UserID) + ".jpg")
+		if err != nil {
+			return nil, 0, err
+		}
+		_, err = file.Write(profile.Photo)
+		if err != nil {
+			return nil, 0, err
+		}
+		count++
+	}
+
+	err := archive.Close()
+	return buf.Bytes(), count, err
+}
+
+// Export отправляет организатору в личные сообщения выгрузку анкет сообщества
+// и, если попросили, архив с фотографиями.
+func (b *MotoBot) Export(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	userID := int64(message.From.ID)
+
+	request, err := parseExport(message.CommandArguments())
+	if err != nil {
+		reply := tgbotapi.NewMessage(userID, fmt.Sprintf("Не удалось разобрать команду: %s.\n"+
+			"Пример: /export json first_name age photos\nДоступные поля: %s.\n"+
+			"Скрытые анкеты выгружаются, если указать all.", err, exportNames()))
+		_, err := b.send(ctx, reply)
+		return err
+	}
+
+	// Анкеты выгружаются страницами, чтобы не загружать всё хранилище одним запросом
+	var profiles []*user.Profile
+	query := storage.ProfileQuery{ChatID: chatID, Limit: exportPageSize}
+	if !request.all {
+		// Скрытые анкеты владельцы убрали из группы, поэтому их контакты без явной просьбы не выгружаются
+		hidden := false
+		query.Hidden = &hidden
+	}
+	for {
+		page, err := b.dataStorage.QueryProfiles(ctx, query)
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, page.Profiles...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	var data []byte
+	if request.format == exportJSON {
+		data, err = encodeJSON(profiles, request.fields)
+	} else {
+		data, err = encodeCSV(profiles, request.fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	name := "profiles-" + time.Now().Format("2006-01-02")
+	document := tgbotapi.NewDocumentUpload(userID, tgbotapi.FileBytes{Name: name + "." + request.format, Bytes: data})
+	document.Caption = fmt.Sprintf("Анкет: %d", len(profiles))
+	_, err = b.send(ctx, document)
+	if err != nil {
+		return err
+	}
+
+	if !request.photos {
+		return nil
+	}
+
+	archive, count, err := zipPhotos(profiles)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		_, err = b.send(ctx, tgbotapi.NewMessage(userID, "В анкетах нет фотографий."))
+		return err
+	}
+	photos := tgbotapi.NewDocumentUpload(userID, tgbotapi.FileBytes{Name: name + "-photos.zip", Bytes: archive})
+	photos.Caption = fmt.Sprintf("Фотографий: %d", count)
+	_, err = b.send(ctx, photos)
+	return err
+}
@@ -0,0 +1,231 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// findPageSize - сколько анкет показывается за один раз по команде /find
+const findPageSize = 5
+
+// Данные инлайн кнопок конструктора поиска
+const (
+	callbackFindRole      = "find_role"
+	callbackFindAge       = "find_age"
+	callbackFindInterests = "find_interests"
+	callbackFindCity      = "find_city"
+	callbackFindPhoto     = "find_photo"
+	callbackFindReset     = "find_reset"
+	callbackFindEdit      = "find_edit"
+	callbackFindSearch    = "find_search"
+	callbackFindMore      = "find_more"
+)
+
+// search - поиск анкет, который пользователь настраивает и листает в личных сообщениях
+type search struct {
+	Filter   profileFilter // Условия поиска
+	Awaiting string        // Кнопка, после которой бот ждёт текстовый ответ с условием
+	Offset   int           // Сколько анкет уже показано
+}
+
+// isFindCallback сообщает, относится ли инлайн кнопка к поиску анкет.
+func isFindCallback(data string) bool {
+	return strings.HasPrefix(data, "find_")
+}
+
+// FindProfiles ищет анкеты сообщества по условиям из аргументов команды /find,
+// а без аргументов открывает конструктор условий поиска.
+func (b *MotoBot) FindProfiles(ctx context.Context, userID int, chatID int64, query string) error {
+	s := &search{Filter: parseSearchQuery(query)}
+	s.Filter.ChatID = chatID
+	b.searches[userID] = s
+
+	if strings.TrimSpace(query) == "" {
+		return b.sendSearchBuilder(ctx, userID, s)
+	}
+	return b.sendSearchPage(ctx, userID, s)
+}
+
+// handleFindCallback обрабатывает кнопки конструктора поиска.
+func (b *MotoBot) handleFindCallback(ctx context.Context, query *tgbotapi.CallbackQuery, chatID int64) error {
+	b.answerCallback(ctx, query.ID)
+
+	userID := query.From.ID
+	s, ok := b.searches[userID]
+	if !ok {
+		s = &search{Filter: profileFilter{ProfileQuery: storage.ProfileQuery{ChatID: chatID}}}
+		b.searches[userID] = s
+	}
+
+	switch query.Data {
+	case callbackFindRole:
+		// Роль переключается по кругу: любая, водители, пассажиры
+		switch {
+		case s.Filter.Driver == nil:
+			driver := true
+			s.Filter.Driver = &driver
+		case *s.Filter.Driver:
+			driver := false
+			s.Filter.Driver = &driver
+		default:
+			s.Filter.Driver = nil
+		}
+	case callbackFindPhoto:
+		s.Filter.HasPhoto = !s.Filter.HasPhoto
+	case callbackFindReset:
+		*s = search{Filter: profileFilter{ProfileQuery: storage.ProfileQuery{ChatID: s.Filter.ChatID}}}
+	case callbackFindEdit:
+		s.Awaiting = ""
+	case callbackFindAge, callbackFindInterests, callbackFindCity:
+		s.Awaiting = query.Data
+		message := tgbotapi.NewMessage(int64(userID), searchInputPrompt(query.Data))
+		_, err := b.send(ctx, message)
+		return err
+	case callbackFindSearch:
+		s.Offset = 0
+		return b.sendSearchPage(ctx, userID, s)
+	case callbackFindMore:
+		return b.sendSearchPage(ctx, userID, s)
+	default:
+		return nil
+	}
+
+	return b.sendSearchBuilder(ctx, userID, s)
+}
+
+// searchInputPrompt возвращает вопрос для условия поиска, которое вводится текстом.
+func searchInputPrompt(data string) string {
+	switch data {
+	case callbackFindAge:
+		return "Введите возраст, например 25, или диапазон, например 20-30. Чтобы убрать условие, отправьте «-»."
+	case callbackFindInterests:
+		return "Введите слова, которые должны встречаться в анкете, через пробел. Чтобы убрать условие, отправьте «-»."
+	default:
+		return "Введите город. Чтобы убрать условие, отправьте «-»."
+	}
+}
+
+// awaitingSearchInput сообщает, ждёт ли бот от пользователя текстовое условие поиска.
+func (b *MotoBot) awaitingSearchInput(userID int) bool {
+	s, ok := b.searches[userID]
+	return ok && s.Awaiting != ""
+}
+
+// applySearchInput записывает введённое текстом условие поиска и снова показывает конструктор.
+func (b *MotoBot) applySearchInput(ctx context.Context, userID int, text string) error {
+	s := b.searches[userID]
+	text = strings.TrimSpace(text)
+	reset := text == "-"
+
+	switch s.Awaiting {
+	case callbackFindAge:
+		if reset {
+			s.Filter.MinAge, s.Filter.MaxAge = 0, 0
+			break
+		}
+		minAge, maxAge, ok := parseAgeRange(text)
+		if !ok {
+			message := tgbotapi.NewMessage(int64(userID), "Возраст нужно указать числом, например 25, или диапазоном, например 20-30.")
+			_, err := b.send(ctx, message)
+			return err
+		}
+		s.Filter.MinAge, s.Filter.MaxAge = minAge, maxAge
+	case callbackFindInterests:
+		s.Filter.Keywords = nil
+		if !reset {
+			s.Filter.Keywords = strings.Fields(strings.ToLower(text))
+		}
+	case callbackFindCity:
+		s.Filter.City = ""
+		if !reset {
+			s.Filter.City = text
+		}
+	}
+
+	s.Awaiting = ""
+	return b.sendSearchBuilder(ctx, userID, s)
+}
+
+// sendSearchBuilder отправляет текущие условия поиска с кнопками для их изменения.
+func (b *MotoBot) sendSearchBuilder(ctx context.Context, userID int, s *search) error {
+	role := "любая"
+	if s.Filter.Driver != nil {
+		role = roleName(&user.Profile{IsDriver: *s.Filter.Driver})
+	}
+	photo := "не важно"
+	if s.Filter.HasPhoto {
+		photo = "обязательно"
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Роль: "+role, callbackFindRole),
+			tgbotapi.NewInlineKeyboardButtonData("Фото: "+photo, callbackFindPhoto),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Возраст", callbackFindAge),
+			tgbotapi.NewInlineKeyboardButtonData("Интересы", callbackFindInterests),
+			tgbotapi.NewInlineKeyboardButtonData("Город", callbackFindCity),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Сбросить", callbackFindReset),
+			tgbotapi.NewInlineKeyboardButtonData("🔍 Искать", callbackFindSearch),
+		),
+	)
+
+	message := tgbotapi.NewMessage(int64(userID), "Условия поиска: "+s.Filter.describe())
+	message.ReplyMarkup = inlineKeyboard
+	_, err := b.send(ctx, message)
+	return err
+}
+
+// sendSearchPage отправляет следующую страницу найденных анкет карточками. Анкеты показываются только
+// участникам группы и без контактов, так как карточки можно переслать кому угодно.
+func (b *MotoBot) sendSearchPage(ctx context.Context, userID int, s *search) error {
+	// Сообщество по умолчанию подставляется и для посторонних, поэтому участие проверяется отдельно
+	var profiles []*user.Profile
+	if b.isMember(ctx, s.Filter.ChatID, userID) {
+		var err error
+		profiles, err = b.searchProfiles(ctx, s.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Offset >= len(profiles) {
+		text := "По запросу «" + s.Filter.describe() + "» анкет не найдено."
+		if s.Offset > 0 {
+			text = "Больше анкет не найдено."
+		}
+		message := tgbotapi.NewMessage(int64(userID), text)
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	end := min(s.Offset+findPageSize, len(profiles))
+	for _, profile := range profiles[s.Offset:end] {
+		caption := b.profileCaption(profile.UserID, profile.ChatID, withoutContacts(profile))
+		_, err := b.send(ctx, profileMessage(int64(userID), caption, profile, nil))
+		if err != nil {
+			return err
+		}
+	}
+	s.Offset = end
+
+	message := tgbotapi.NewMessage(int64(userID), fmt.Sprintf("Показано %d из %d.", end, len(profiles)))
+	if end < len(profiles) {
+		message.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Показать ещё", callbackFindMore),
+				tgbotapi.NewInlineKeyboardButtonData("Изменить условия", callbackFindEdit),
+			),
+		)
+	}
+	_, err := b.send(ctx, message)
+	return err
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/storage"
+)
+
+// Данные инлайн кнопок подтверждения удаления всех данных пользователя
+const (
+	callbackForgetConfirm = "forget_confirm"
+	callbackForgetCancel  = "forget_cancel"
+)
+
+// ForgetMe спрашивает пользователя, действительно ли удалить всё, что бот о нём хранит.
+func (b *MotoBot) ForgetMe(ctx context.Context, userID int) error {
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Удалить всё", callbackForgetConfirm),
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", callbackForgetCancel),
+		),
+	)
+	message := tgbotapi.NewMessage(int64(userID), "Бот удалит анкету и её публикацию в группе, фотографию, "+
+		"незавершённое заполнение анкеты, выданные вам роли и историю изменений анкеты. Восстановить данные будет нельзя. Продолжить?")
+	message.ReplyMarkup = inlineKeyboard
+
+	_, err := b.send(ctx, message)
+	return err
+}
+
+// handleForgetCallback удаляет данные пользователя после подтверждения или сообщает об отмене.
+func (b *MotoBot) handleForgetCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	b.answerCallback(ctx, query.ID)
+
+	userID := query.From.ID
+	if query.Data == callbackForgetCancel {
+		message := tgbotapi.NewMessage(int64(userID), "Удаление отменено, ваши данные сохранены.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	err := b.forgetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), "Все ваши данные удалены. Чтобы снова появиться в группе, создайте анкету командой /start.")
+	_, err = b.send(ctx, message)
+	return err
+}
+
+// forgetUser удаляет всё, что бот хранит о пользователе: анкеты во всех группах вместе с публикациями,
+// незавершённое заполнение анкеты, выданные роли, записи журнала аудита и состояние в памяти бота.
+func (b *MotoBot) forgetUser(ctx context.Context, userID int) error {
+	page, err := b.dataStorage.QueryProfiles(ctx, storage.ProfileQuery{UserID: userID})
+	if err != nil {
+		return err
+	}
+	for _, profile := range page.Profiles {
+		b.deleteProfilePost(ctx, profile)
+		err = b.dataStorage.DeleteProfile(ctx, profile.ChatID, userID)
+		if err != nil {
+			return err
+		}
+		metrics.ProfileEvents.WithLabelValues(metrics.ProfileDeleted).Inc()
+	}
+
+	err = b.dataStorage.DeleteSession(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	grants, err := b.dataStorage.ListGrants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		switch {
+		case grant.UserID == userID:
+			err = b.dataStorage.DeleteGrant(ctx, grant.ChatID, userID)
+		case grant.GrantedBy == userID:
+			// Роли, выданные пользователем, остаются, но без его идентификатора
+			anonymous := *grant
+			anonymous.GrantedBy = 0
+			err = b.dataStorage.SaveGrant(ctx, &anonymous)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.dataStorage.DeleteAudit(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	delete(b.searches, userID)
+	delete(b.lastCommunity, userID)
+	b.forgetUsername(userID)
+	delete(b.broadcasts, userID)
+
+	logging.FromContext(ctx).Info("Данные пользователя удалены по его запросу", "user_id", userID)
+	return nil
+}
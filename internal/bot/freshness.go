@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// freshnessCheckInterval - как часто проверяется актуальность анкет
+const freshnessCheckInterval = time.Hour
+
+// defaultBumpCooldown - как часто можно поднимать анкету командой /up
+const defaultBumpCooldown = 24 * time.Hour
+
+// Данные инлайн кнопок вопроса об актуальности анкеты. К ним через двоеточие добавляется группа анкеты
+const (
+	callbackFreshConfirm = "fresh_confirm"
+	callbackFreshHide    = "fresh_hide"
+)
+
+// checkFreshness спрашивает владельцев давно не обновлявшихся анкет, актуальны ли они,
+// и снимает из группы анкеты, владельцы которых не ответили.
+func (b *MotoBot) checkFreshness(ctx context.Context) {
+	// Политика актуальности отключена
+	if b.settings.FreshnessAskAfter <= 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+
+	profiles, err := b.dataStorage.ListProfiles(ctx)
+	if err != nil {
+		logger.Error("Ошибка при получении анкет", "error", err)
+		return
+	}
+
+	for _, profile := range profiles {
+		if profile.Hidden {
+			continue
+		}
+
+		switch {
+		case profile.AskedAt.IsZero() && time.Since(profile.LastActiveAt()) > b.settings.FreshnessAskAfter:
+			err = b.askFreshness(ctx, profile)
+		case !profile.AskedAt.IsZero() && time.Since(profile.AskedAt) > b.settings.FreshnessExpireAfter:
+			err = b.expireProfile(ctx, profile)
+		default:
+			continue
+		}
+		if err != nil {
+			logger.Error("Ошибка при проверке актуальности анкеты", "error", err, "user_id", profile.UserID)
+		}
+	}
+}
+
+// askFreshness спрашивает владельца, актуальна ли его анкета, и запускает срок ожидания ответа.
+func (b *MotoBot) askFreshness(ctx context.Context, profile *user.Profile) error {
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Да, актуальна", profileCallbackData(callbackFreshConfirm, profile.ChatID)),
+			tgbotapi.NewInlineKeyboardButtonData("Скрыть анкету", profileCallbackData(callbackFreshHide, profile.ChatID)),
+		),
+	)
+	days := int(b.settings.FreshnessExpireAfter.Hours() / 24)
+	text := fmt.Sprintf("Ваша анкета давно не обновлялась. Она всё ещё актуальна? Если не ответить в течение %d дн., анкета будет снята из группы.", days)
+	message := tgbotapi.NewMessage(int64(profile.UserID), text)
+	message.ReplyMarkup = inlineKeyboard
+
+	// Если владелец заблокировал бота, ответа не будет, и анкета снимется по истечении срока
+	_, err := b.send(ctx, message)
+	if isForbidden(err) {
+		logging.FromContext(ctx).Info("Владелец анкеты заблокировал бота, вопрос об актуальности не доставлен",
+			"user_id", profile.UserID, "chat_id", profile.ChatID)
+	} else if err != nil {
+		return err
+	}
+
+	profile.AskedAt = time.Now()
+	return b.dataStorage.SaveProfile(ctx, profile)
+}
+
+// expireProfile снимает из группы анкету, владелец которой не подтвердил её актуальность.
+func (b *MotoBot) expireProfile(ctx context.Context, profile *user.Profile) error {
+	b.deleteProfilePost(ctx, profile)
+
+	profile.MessageID = 0
+	profile.Hidden = true
+	profile.Expired = true
+	profile.AskedAt = time.Time{}
+	err := b.dataStorage.SaveProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+	b.auditProfile(ctx, profile, 0, user.AuditExpired, "")
+
+	message := tgbotapi.NewMessage(int64(profile.UserID), "Ваша анкета снята из группы, так как вы не подтвердили её актуальность. Вернуть её можно командой /show.")
+	b.post(ctx, message)
+	return nil
+}
+
+// confirmProfile отмечает, что владелец подтвердил актуальность анкеты.
+func (b *MotoBot) confirmProfile(ctx context.Context, userID int, chatID int64) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	profile.ConfirmedAt = time.Now()
+	profile.AskedAt = time.Time{}
+	err = b.dataStorage.SaveProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), "Спасибо! Анкета остаётся в группе. Поднять её вниз чата можно командой /up.")
+	_, err = b.send(ctx, message)
+	return err
+}
+
+// BumpProfile заново публикует анкету, чтобы она оказалась внизу чата группы.
+func (b *MotoBot) BumpProfile(ctx context.Context, userID int, chatID int64) error {
+	profile, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		message := tgbotapi.NewMessage(int64(userID), "Ваш профиль не найден. Создайте анкету с помощью команды /start.")
+		_, sendErr := b.send(ctx, message)
+		if sendErr != nil {
+			logging.FromContext(ctx).Error("Ошибка отправки сообщения", "error", sendErr)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if profile.Hidden {
+		message := tgbotapi.NewMessage(int64(userID), "Анкета скрыта. Чтобы вернуть её в группу, отправьте /show.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	// Поднимать анкету можно не чаще, чем раз в заданный период
+	if next := profile.BumpedAt.Add(b.settings.BumpCooldown); time.Now().Before(next) {
+		text := fmt.Sprintf("Анкету можно поднять не раньше %s.", next.Format("02.01.2006 15:04"))
+		message := tgbotapi.NewMessage(int64(userID), text)
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	b.deleteProfilePost(ctx, profile)
+
+	// Поднятие анкеты заодно подтверждает её актуальность
+	profile.BumpedAt = time.Now()
+	profile.AskedAt = time.Time{}
+	err = b.SendProfile(ctx, userID, profile.ChatID, profile)
+	if err != nil {
+		return err
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), "Анкета поднята вниз чата группы.")
+	_, err = b.send(ctx, message)
+	return err
+}
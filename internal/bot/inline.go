@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// inlinePageSize - сколько анкет возвращается на один инлайн запрос. Telegram допускает не больше 50
+const inlinePageSize = 20
+
+// inlineCacheTime - сколько секунд Telegram может кэшировать ответ на инлайн запрос
+const inlineCacheTime = 60
+
+// inlineQueryResultCachedPhoto - результат инлайн запроса с уже загруженной в Telegram фотографией.
+// В используемой версии библиотеки такого типа нет.
+type inlineQueryResultCachedPhoto struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	PhotoFileID string `json:"photo_file_id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+}
+
+// answerInlineQuery отвечает на запрос вида "@MotoBot водитель 25" подходящими анкетами.
+// Инлайн режим должен быть включён для бота в @BotFather. Искать могут только участники групп сообществ.
+func (b *MotoBot) answerInlineQuery(ctx context.Context, query *tgbotapi.InlineQuery) error {
+	// Ищутся анкеты сообщества пользователя
+	filter := parseSearchQuery(query.Query)
+	filter.ChatID = b.resolveCommunity(ctx, query.From.ID, nil)
+
+	// Сообщество по умолчанию подставляется и для посторонних, поэтому участие проверяется отдельно
+	var profiles []*user.Profile
+	if b.isMember(ctx, filter.ChatID, query.From.ID) {
+		var err error
+		profiles, err = b.searchProfiles(ctx, filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Смещение - номер первой анкеты следующей страницы
+	offset, _ := strconv.Atoi(query.Offset)
+	if offset < 0 || offset > len(profiles) {
+		offset = len(profiles)
+	}
+	end := min(offset+inlinePageSize, len(profiles))
+
+	results := make([]interface{}, 0, end-offset)
+	for _, profile := range profiles[offset:end] {
+		results = append(results, b.inlineResult(profile))
+	}
+
+	config := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		// Выдача зависит от сообщества пользователя, поэтому кэшируется для каждого пользователя отдельно
+		IsPersonal: true,
+	}
+	if end < len(profiles) {
+		config.NextOffset = strconv.Itoa(end)
+	}
+
+	var answerErr error
+	done := make(chan struct{})
+	enqueueErr := b.outbox.enqueue(ctx, func() {
+		defer close(done)
+		start := time.Now()
+		_, answerErr = b.bot.AnswerInlineQuery(config)
+		metrics.ObserveTelegram("answerInlineQuery", start, answerErr)
+	})
+	if enqueueErr != nil {
+		return enqueueErr
+	}
+
+	<-done
+	return answerErr
+}
+
+// inlineResult готовит анкету для выдачи в инлайн режиме: с фотографией, если она есть, иначе текстом.
+// Результат можно переслать в любой чат, поэтому контакты в него не попадают.
+func (b *MotoBot) inlineResult(profile *user.Profile) interface{} {
+	id := strconv.Itoa(profile.UserID)
+	title := profile.FirstName + " " + profile.LastName
+	description := fmt.Sprintf("%s, %d лет", roleName(profile), profile.Age)
+	caption := b.profileCaption(profile.UserID, profile.ChatID, withoutContacts(profile))
+
+	if profile.PhotoFileID != "" {
+		return inlineQueryResultCachedPhoto{
+			Type:        "photo",
+			ID:          id,
+			PhotoFileID: profile.PhotoFileID,
+			Title:       title,
+			Description: description,
+			Caption:     caption,
+		}
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticle(id, title, caption)
+	article.Description = description
+	return article
+}
+
+// roleName возвращает роль участника для кратких описаний анкеты.
+func roleName(profile *user.Profile) string {
+	if profile.IsDriver {
+		return "🏍️ Водитель"
+	}
+	return "🚶 Пассажир"
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// personalData - всё, что бот хранит о пользователе
+type personalData struct {
+	UserID     int                `json:"user_id"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Profiles   []*user.Profile    `json:"profiles,omitempty"` // Анкеты во всех группах, фотографии лежат в архиве отдельными файлами
+	Session    *user.Session      `json:"session,omitempty"`  // Незавершённое заполнение анкеты
+	Grants     []*user.Grant      `json:"grants,omitempty"`   // Выданные пользователю роли
+	Audit      []*user.AuditEntry `json:"audit,omitempty"`    // Записи журнала аудита о пользователе
+}
+
+// collectPersonalData собирает из хранилища все данные пользователя и фотографии его анкет по именам файлов.
+func (b *MotoBot) collectPersonalData(ctx context.Context, userID int) (*personalData, map[string][]byte, error) {
+	data := &personalData{UserID: userID, ExportedAt: time.Now()}
+	photos := make(map[string][]byte)
+
+	page, err := b.dataStorage.QueryProfiles(ctx, storage.ProfileQuery{UserID: userID})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, profile := range page.Profiles {
+		copied := *profile
+		if len(copied.Photo) > 0 {
+			photos["photo-"+strconv.FormatInt(copied.ChatID, 10)+".jpg"] = copied.Photo
+		}
+		copied.Photo = nil
+		data.Profiles = append(data.Profiles, &copied)
+	}
+
+	session, err := b.dataStorage.GetSession(ctx, userID)
+	switch {
+	case err == nil:
+		copied := *session
+		copied.Profile.Photo = nil
+		data.Session = &copied
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, nil, err
+	}
+
+	grants, err := b.dataStorage.ListGrants(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, grant := range grants {
+		if grant.UserID == userID {
+			data.Grants = append(data.Grants, grant)
+		}
+	}
+
+	entries, err := b.dataStorage.ListAudit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			data.Audit = append(data.Audit, entry)
+		}
+	}
+
+	return data, photos, nil
+}
+
+// MyData отправляет пользователю архив со всеми данными, которые бот о нём хранит.
+func (b *MotoBot) MyData(ctx context.Context, userID int) error {
+	data, photos, err := b.collectPersonalData(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if len(data.Profiles) == 0 && data.Session == nil && len(data.Grants) == 0 && len(data.Audit) == 0 {
+		message := tgbotapi.NewMessage(int64(userID), "Бот не хранит о вас никаких данных.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("data.json")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(encoded)
+	if err != nil {
+		return err
+	}
+	for name, photo := range photos {
+		file, err = archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(photo)
+		if err != nil {
+			return err
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+
+	document := tgbotapi.NewDocumentUpload(int64(userID), tgbotapi.FileBytes{Name: "mydata.zip", Bytes: buf.Bytes()})
+	document.Caption = "Все данные, которые бот хранит о вас: анкеты, незавершённое заполнение анкеты, роли в сообществах и история изменений. Удалить их можно командой /forget_me."
+	_, err = b.send(ctx, document)
+	return err
+}
@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+)
+
+// errOutboxClosed возвращается при попытке отправки после остановки бота.
+var errOutboxClosed = errors.New("очередь исходящих сообщений закрыта")
+
+// outbox - очередь исходящих запросов к Telegram API.
+// Запросы выполняются по порядку одной горутиной, поэтому при остановке
+// бота можно дождаться, пока все поставленные в очередь сообщения будут отправлены.
+type outbox struct {
+	jobs   chan func()
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// newOutbox создает очередь заданного размера и запускает её обработку.
+func newOutbox(size int) *outbox {
+	o := &outbox{
+		jobs: make(chan func(), size),
+		done: make(chan struct{}),
+	}
+	go o.loop()
+	return o
+}
+
+func (o *outbox) loop() {
+	defer close(o.done)
+	for job := range o.jobs {
+		job()
+	}
+}
+
+// enqueue ставит запрос в очередь.
+func (o *outbox) enqueue(ctx context.Context, job func()) error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.closed {
+		return errOutboxClosed
+	}
+
+	select {
+	case o.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close перестает принимать новые запросы и дожидается выполнения уже поставленных.
+func (o *outbox) close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.jobs)
+	}
+	o.mu.Unlock()
+
+	<-o.done
+}
+
+// send ставит сообщение в очередь и дожидается результата его отправки.
+func (b *MotoBot) send(ctx context.Context, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var (
+		message tgbotapi.Message
+		err     error
+	)
+	done := make(chan struct{})
+
+	enqueueErr := b.outbox.enqueue(ctx, func() {
+		defer close(done)
+		start := time.Now()
+		message, err = b.bot.Send(c)
+		metrics.ObserveTelegram(telegramMethod(c), start, err)
+	})
+	if enqueueErr != nil {
+		return tgbotapi.Message{}, enqueueErr
+	}
+
+	<-done
+	return message, err
+}
+
+// post ставит сообщение в очередь, не дожидаясь отправки. Ошибки только логируются.
+func (b *MotoBot) post(ctx context.Context, c tgbotapi.Chattable) {
+	logger := logging.FromContext(ctx)
+	err := b.outbox.enqueue(ctx, func() {
+		start := time.Now()
+		_, err := b.bot.Send(c)
+		metrics.ObserveTelegram(telegramMethod(c), start, err)
+		if err != nil {
+			logger.Error("Ошибка при отправке сообщения", "error", err)
+		}
+	})
+	if err != nil {
+		logger.Error("Ошибка при постановке сообщения в очередь", "error", err)
+	}
+}
+
+// deleteMessage удаляет сообщение через очередь исходящих запросов.
+// Если сообщения уже нет, например его удалил администратор, это не считается ошибкой.
+func (b *MotoBot) deleteMessage(ctx context.Context, chatID int64, messageID int) error {
+	var err error
+	done := make(chan struct{})
+
+	enqueueErr := b.outbox.enqueue(ctx, func() {
+		defer close(done)
+		start := time.Now()
+		_, err = b.bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, messageID))
+		metrics.ObserveTelegram("deleteMessage", start, err)
+	})
+	if enqueueErr != nil {
+		return enqueueErr
+	}
+
+	<-done
+	if isMessageNotFound(err) {
+		logging.FromContext(ctx).Info("Удаляемого сообщения уже нет", "chat_id", chatID, "message_id", messageID)
+		return nil
+	}
+	return err
+}
+
+// request выполняет произвольный метод Telegram API через очередь исходящих запросов.
+func (b *MotoBot) request(ctx context.Context, method string, params url.Values) (tgbotapi.APIResponse, error) {
+	var (
+		resp tgbotapi.APIResponse
+		err  error
+	)
+	done := make(chan struct{})
+
+	enqueueErr := b.outbox.enqueue(ctx, func() {
+		defer close(done)
+		start := time.Now()
+		resp, err = b.bot.MakeRequest(method, params)
+		metrics.ObserveTelegram(method, start, err)
+	})
+	if enqueueErr != nil {
+		return tgbotapi.APIResponse{}, enqueueErr
+	}
+
+	<-done
+	return resp, err
+}
+
+// telegramMethod возвращает название метода Telegram API для метрик.
+func telegramMethod(c tgbotapi.Chattable) string {
+	switch c.(type) {
+	case tgbotapi.MessageConfig:
+		return "sendMessage"
+	case tgbotapi.PhotoConfig:
+		return "sendPhoto"
+	case tgbotapi.DocumentConfig:
+		return "sendDocument"
+	case tgbotapi.EditMessageTextConfig:
+		return "editMessageText"
+	case tgbotapi.EditMessageCaptionConfig:
+		return "editMessageCaption"
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return "editMessageReplyMarkup"
+	default:
+		return "other"
+	}
+}
+
+// answerCallback подтверждает нажатие инлайн кнопки, чтобы у пользователя пропал индикатор загрузки.
+func (b *MotoBot) answerCallback(ctx context.Context, callbackID string) {
+	logger := logging.FromContext(ctx)
+	err := b.outbox.enqueue(ctx, func() {
+		start := time.Now()
+		_, err := b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackID, ""))
+		metrics.ObserveTelegram("answerCallbackQuery", start, err)
+		if err != nil {
+			logger.Error("Ошибка при ответе на нажатие кнопки", "error", err)
+		}
+	})
+	if err != nil {
+		logger.Error("Ошибка при постановке сообщения в очередь", "error", err)
+	}
+}
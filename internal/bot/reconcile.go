@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/storage"
+)
+
+// Политики сверки анкет с сообщениями в группе
+const (
+	ReconcileRepublish = "republish" // Заново опубликовать анкету, сообщение с которой пропало
+	ReconcileClear     = "clear"     // Только забыть номер пропавшего сообщения
+)
+
+// defaultReconcileInterval - как часто анкеты сверяются с сообщениями в группе
+const defaultReconcileInterval = 6 * time.Hour
+
+// reconcileReport - итог сверки анкет с сообщениями в группе
+type reconcileReport struct {
+	Checked     int // Сколько сообщений проверено
+	Missing     int // Сколько сообщений не нашлось в группе
+	Republished int // Сколько анкет опубликовано заново
+	Cleared     int // Сколько номеров сообщений забыто
+	Failed      int // Сколько анкет не удалось проверить или исправить
+}
+
+// isMessageNotFound сообщает, что сообщения в чате уже нет, например его удалил администратор.
+func isMessageNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	text := err.Error()
+	return strings.Contains(text, "message to delete not found") ||
+		strings.Contains(text, "message to edit not found") ||
+		strings.Contains(text, "MESSAGE_ID_INVALID")
+}
+
+// messageExists проверяет, есть ли сообщение в группе. В Bot API нет метода для получения
+// сообщения, поэтому у сообщения убирается пустая клавиатура: на существующее сообщение
+// Telegram ответит, что оно не изменилось.
+func (b *MotoBot) messageExists(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	_, err := b.send(ctx, edit)
+	switch {
+	case err == nil, isNotModified(err):
+		return true, nil
+	case isMessageNotFound(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// reconcileTarget - анкета, сообщение которой проверяется при сверке
+type reconcileTarget struct {
+	UserID    int   // Владелец анкеты
+	ChatID    int64 // Группа, в которой опубликована анкета
+	MessageID int   // Номер сообщения на момент начала сверки, 0 - анкета не опубликована
+}
+
+// reconcileCheck - результат проверки сообщений, который применяется в цикле обработки обновлений
+type reconcileCheck struct {
+	ReplyTo int               // Администратор, запустивший сверку командой, 0 - плановая сверка
+	Missing []reconcileTarget // Анкеты, сообщений которых нет в группе
+	Report  reconcileReport   // Итог проверки
+}
+
+// startReconcile запускает сверку анкет с сообщениями в группе. Если chatID не равен нулю,
+// сверяются только анкеты этой группы. Сообщения проверяются в фоне по одному запросу к Telegram
+// на анкету, чтобы не задерживать обработку обновлений, а исправления применяются в цикле
+// обработки обновлений через канал reconciled. Возвращает false, если сверка уже идёт.
+func (b *MotoBot) startReconcile(ctx context.Context, chatID int64, replyTo int) (bool, error) {
+	if b.reconciling {
+		return false, nil
+	}
+
+	hidden := false
+	page, err := b.dataStorage.QueryProfiles(ctx, storage.ProfileQuery{ChatID: chatID, Hidden: &hidden})
+	if err != nil {
+		return false, err
+	}
+
+	// Номера сообщений копируются заранее, так как анкеты меняются при обработке обновлений
+	var targets []reconcileTarget
+	for _, profile := range page.Profiles {
+		// Участник вышел из группы и ещё может вернуться, анкету снимет политика выхода
+		if !profile.LeftAt.IsZero() {
+			continue
+		}
+		targets = append(targets, reconcileTarget{UserID: profile.UserID, ChatID: profile.ChatID, MessageID: profile.MessageID})
+	}
+
+	b.reconciling = true
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		check := b.checkPosts(ctx, targets)
+		check.ReplyTo = replyTo
+		select {
+		case b.reconciled <- check:
+		case <-ctx.Done():
+		}
+	}()
+	return true, nil
+}
+
+// checkPosts проверяет, есть ли в группе сообщения с анкетами.
+func (b *MotoBot) checkPosts(ctx context.Context, targets []reconcileTarget) reconcileCheck {
+	logger := logging.FromContext(ctx)
+	var check reconcileCheck
+
+	for _, target := range targets {
+		if target.MessageID != 0 {
+			check.Report.Checked++
+			exists, err := b.messageExists(ctx, target.ChatID, target.MessageID)
+			if err != nil {
+				check.Report.Failed++
+				logger.Error("Ошибка при проверке сообщения с анкетой", "error", err, "user_id", target.UserID, "message_id", target.MessageID)
+				continue
+			}
+			if exists {
+				continue
+			}
+			check.Report.Missing++
+			logger.Info("Сообщение с анкетой пропало из группы", "user_id", target.UserID, "message_id", target.MessageID)
+		}
+		check.Missing = append(check.Missing, target)
+	}
+	return check
+}
+
+// applyReconcile исправляет расхождения, найденные при сверке, согласно политике:
+// публикует пропавшие анкеты заново или забывает номер сообщения, и сообщает итог.
+func (b *MotoBot) applyReconcile(ctx context.Context, check reconcileCheck) {
+	logger := logging.FromContext(ctx)
+	b.reconciling = false
+	report := check.Report
+
+	for _, target := range check.Missing {
+		profile, err := b.dataStorage.GetProfile(ctx, target.ChatID, target.UserID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			report.Failed++
+			logger.Error("Ошибка при получении анкеты", "error", err, "user_id", target.UserID)
+			continue
+		}
+		// Пока шла проверка, анкету могли скрыть, опубликовать заново или её владелец мог выйти из группы
+		if profile.Hidden || profile.MessageID != target.MessageID || !profile.LeftAt.IsZero() {
+			continue
+		}
+
+		if b.settings.ReconcilePolicy == ReconcileRepublish {
+			err = b.SendProfile(ctx, profile.UserID, target.ChatID, profile)
+			if err != nil {
+				report.Failed++
+				logger.Error("Ошибка при повторной публикации анкеты", "error", err, "user_id", profile.UserID)
+				continue
+			}
+			report.Republished++
+			continue
+		}
+
+		if profile.MessageID == 0 {
+			continue
+		}
+		profile.MessageID = 0
+		err = b.dataStorage.SaveProfile(ctx, profile)
+		if err != nil {
+			report.Failed++
+			logger.Error("Ошибка при сохранении анкеты", "error", err, "user_id", profile.UserID)
+			continue
+		}
+		report.Cleared++
+	}
+
+	logger.Info("Сверка анкет с группой завершена",
+		"checked", report.Checked, "missing", report.Missing,
+		"republished", report.Republished, "cleared", report.Cleared, "failed", report.Failed)
+
+	if check.ReplyTo == 0 {
+		return
+	}
+	text := fmt.Sprintf("Сверка завершена.\nПроверено сообщений: %d\nПропало: %d\nОпубликовано заново: %d\nЗабыто: %d\nОшибок: %d",
+		report.Checked, report.Missing, report.Republished, report.Cleared, report.Failed)
+	b.post(ctx, tgbotapi.NewMessage(int64(check.ReplyTo), text))
+}
+
+// runReconcile запускает плановую сверку анкет, если предыдущая уже завершилась.
+func (b *MotoBot) runReconcile(ctx context.Context) {
+	started, err := b.startReconcile(ctx, 0, 0)
+	if err != nil {
+		logging.FromContext(ctx).Error("Ошибка при сверке анкет с группой", "error", err)
+		return
+	}
+	if !started {
+		logging.FromContext(ctx).Warn("Предыдущая сверка анкет ещё не завершилась, плановая сверка пропущена")
+	}
+}
+
+// Reconcile запускает сверку анкет с сообщениями в группе сообщества по команде администратора.
+// Итог сверки придёт администратору отдельным сообщением.
+func (b *MotoBot) Reconcile(ctx context.Context, userID int, chatID int64) error {
+	started, err := b.startReconcile(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	text := "Сверка запущена, итог придёт отдельным сообщением."
+	if !started {
+		text = "Сверка уже идёт, дождитесь её итога."
+	}
+	_, err = b.send(ctx, tgbotapi.NewMessage(int64(userID), text))
+	return err
+}
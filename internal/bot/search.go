@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// profileFilter - условия поиска анкет. Пустые поля не ограничивают поиск. Группу, роль и возраст
+// отбирает хранилище по ProfileQuery, остальные условия проверяются в match.
+type profileFilter struct {
+	storage.ProfileQuery
+
+	Keywords []string // Слова, которые должны встречаться в имени или интересах
+	City     string   // Город
+	HasPhoto bool     // Только анкеты с фотографией
+}
+
+// parseSearchQuery разбирает строку поиска вида "водитель 25" или "пассажир 20-30 эндуро город:Москва фото".
+func parseSearchQuery(query string) profileFilter {
+	var filter profileFilter
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		switch word {
+		case "driver", "водитель", "водители":
+			driver := true
+			filter.Driver = &driver
+			continue
+		case "passenger", "пассажир", "пассажиры":
+			driver := false
+			filter.Driver = &driver
+			continue
+		case "photo", "фото":
+			filter.HasPhoto = true
+			continue
+		}
+
+		if city, ok := cutAnyPrefix(word, "город:", "city:"); ok {
+			filter.City = city
+			continue
+		}
+
+		if minAge, maxAge, ok := parseAgeRange(word); ok {
+			filter.MinAge, filter.MaxAge = minAge, maxAge
+			continue
+		}
+
+		filter.Keywords = append(filter.Keywords, word)
+	}
+	return filter
+}
+
+// cutAnyPrefix отрезает от слова первый подходящий префикс.
+func cutAnyPrefix(word string, prefixes ...string) (string, bool) {
+	for _, prefix := range prefixes {
+		if rest, ok := strings.CutPrefix(word, prefix); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// parseAgeRange разбирает возраст "25" или диапазон возрастов "20-30".
+func parseAgeRange(word string) (int, int, bool) {
+	from, to, isRange := strings.Cut(word, "-")
+	minAge, err := strconv.Atoi(from)
+	if err != nil || minAge <= 0 {
+		return 0, 0, false
+	}
+	if !isRange {
+		return minAge, minAge, true
+	}
+
+	maxAge, err := strconv.Atoi(to)
+	if err != nil || maxAge < minAge {
+		return 0, 0, false
+	}
+	return minAge, maxAge, true
+}
+
+// match проверяет условия поиска, которых нет в ProfileQuery: город, фотографию и ключевые слова.
+func (f profileFilter) match(profile *user.Profile) bool {
+	if f.City != "" && !strings.Contains(strings.ToLower(profile.City), strings.ToLower(f.City)) {
+		return false
+	}
+	if f.HasPhoto && len(profile.Photo) == 0 {
+		return false
+	}
+
+	text := strings.ToLower(profile.FirstName + " " + profile.LastName + " " + profile.Interests)
+	for _, keyword := range f.Keywords {
+		if !strings.Contains(text, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe перечисляет условия поиска для пользователя.
+func (f profileFilter) describe() string {
+	var parts []string
+	if f.Driver != nil {
+		if *f.Driver {
+			parts = append(parts, "водители")
+		} else {
+			parts = append(parts, "пассажиры")
+		}
+	}
+	switch {
+	case f.MinAge > 0 && f.MinAge == f.MaxAge:
+		parts = append(parts, fmt.Sprintf("возраст %d", f.MinAge))
+	case f.MinAge > 0:
+		parts = append(parts, fmt.Sprintf("возраст %d-%d", f.MinAge, f.MaxAge))
+	}
+	if len(f.Keywords) > 0 {
+		parts = append(parts, "интересы: "+strings.Join(f.Keywords, ", "))
+	}
+	if f.City != "" {
+		parts = append(parts, "город: "+f.City)
+	}
+	if f.HasPhoto {
+		parts = append(parts, "с фотографией")
+	}
+
+	if len(parts) == 0 {
+		return "без ограничений"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withoutContacts возвращает копию анкеты без контактов для показа в результатах поиска.
+func withoutContacts(profile *user.Profile) *user.Profile {
+	public := *profile
+	public.Contacts = ""
+	return &public
+}
+
+// searchProfiles возвращает опубликованные анкеты, подходящие под условия поиска.
+// Скрытые анкеты в поиске не участвуют. Анкеты упорядочены от недавно обновлённых к старым,
+// чтобы страницы результатов не перемешивались между запросами.
+func (b *MotoBot) searchProfiles(ctx context.Context, filter profileFilter) ([]*user.Profile, error) {
+	query := filter.ProfileQuery
+	hidden := false
+	query.Hidden = &hidden
+	page, err := b.dataStorage.QueryProfiles(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*user.Profile
+	for _, profile := range page.Profiles {
+		if filter.match(profile) {
+			found = append(found, profile)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		ti, tj := found[i].LastActiveAt(), found[j].LastActiveAt()
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return found[i].UserID < found[j].UserID
+	})
+	return found, nil
+}
@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// defaultStatsInterval - как часто по умолчанию отправляется плановый отчёт со статистикой
+const defaultStatsInterval = statsWeek
+
+// statsWeek - за какой период считаются новые анкеты
+const statsWeek = 7 * 24 * time.Hour
+
+// ageGroups - границы возрастных групп в статистике
+var ageGroups = []struct {
+	label    string
+	min, max int
+}{
+	{"до 18", 0, 17},
+	{"18-24", 18, 24},
+	{"25-34", 25, 34},
+	{"35-44", 35, 44},
+	{"45 и старше", 45, 1 << 30},
+}
+
+// communityStats - статистика анкет сообщества
+type communityStats struct {
+	Profiles    int   // Всего анкет
+	Drivers     int   // Водителей
+	Passengers  int   // Пассажиров
+	Ages        []int // Количество анкет по возрастным группам ageGroups
+	CreatedWeek int   // Создано за последнюю неделю
+	Active      int   // Опубликовано в группе
+	Hidden      int   // Скрыто, в том числе снято за неактуальностью
+	Unfinished  int   // Анкет, которые сейчас заполняются
+	Completed   int   // Заполнений, закончившихся публикацией анкеты
+	Abandoned   int   // Заполнений, отменённых или брошенных по таймауту
+}
+
+// completionRate возвращает долю завершённых заполнений анкеты в процентах среди всех закончившихся:
+// опубликованных, отменённых и брошенных по таймауту. Если ни одно заполнение ещё не закончилось, ok равен false.
+func (s communityStats) completionRate() (rate float64, ok bool) {
+	total := s.Completed + s.Abandoned
+	if total == 0 {
+		return 0, false
+	}
+	return float64(s.Completed) * 100 / float64(total), true
+}
+
+// recordWizardOutcome учитывает в итогах заполнения анкеты группы завершённое или брошенное заполнение.
+// Ошибка только записывается в лог, чтобы статистика не мешала пользователю заполнять анкету.
+func (b *MotoBot) recordWizardOutcome(ctx context.Context, chatID int64, completed bool) {
+	logger := logging.FromContext(ctx)
+
+	stats, err := b.dataStorage.GetWizardStats(ctx, chatID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		stats = &user.WizardStats{ChatID: chatID}
+	case err != nil:
+		logger.Error("Ошибка при получении итогов заполнения анкеты", "error", err, "chat_id", chatID)
+		return
+	}
+
+	if completed {
+		stats.Completed++
+	} else {
+		stats.Abandoned++
+	}
+	err = b.dataStorage.SaveWizardStats(ctx, stats)
+	if err != nil {
+		logger.Error("Ошибка при сохранении итогов заполнения анкеты", "error", err, "chat_id", chatID)
+	}
+}
+
+// collectStats считает статистику анкет сообщества по данным хранилища.
+func (b *MotoBot) collectStats(ctx context.Context, chatID int64) (communityStats, error) {
+	stats := communityStats{Ages: make([]int, len(ageGroups))}
+
+	// Каждый показатель считает хранилище, анкеты целиком не загружаются
+	driver, hidden := true, true
+	type counter struct {
+		value *int
+		query storage.ProfileQuery
+	}
+	counters := []counter{
+		{&stats.Profiles, storage.ProfileQuery{ChatID: chatID}},
+		{&stats.Drivers, storage.ProfileQuery{ChatID: chatID, Driver: &driver}},
+		{&stats.Hidden, storage.ProfileQuery{ChatID: chatID, Hidden: &hidden}},
+		{&stats.CreatedWeek, storage.ProfileQuery{ChatID: chatID, CreatedAfter: time.Now().Add(-statsWeek)}},
+	}
+	for i, group := range ageGroups {
+		counters = append(counters, counter{&stats.Ages[i], storage.ProfileQuery{ChatID: chatID, MinAge: group.min, MaxAge: group.max}})
+	}
+	for _, counter := range counters {
+		var err error
+		*counter.value, err = b.dataStorage.CountProfiles(ctx, counter.query)
+		if err != nil {
+			return stats, err
+		}
+	}
+	stats.Passengers = stats.Profiles - stats.Drivers
+	stats.Active = stats.Profiles - stats.Hidden
+
+	sessions, err := b.dataStorage.ListSessions(ctx)
+	if err != nil {
+		return stats, err
+	}
+	for _, session := range sessions {
+		if !session.Editing && session.ChatID == chatID {
+			stats.Unfinished++
+		}
+	}
+
+	wizard, err := b.dataStorage.GetWizardStats(ctx, chatID)
+	switch {
+	case err == nil:
+		stats.Completed = wizard.Completed
+		stats.Abandoned = wizard.Abandoned
+	case !errors.Is(err, storage.ErrNotFound):
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// statsReport формирует текст отчёта со статистикой сообщества.
+func (b *MotoBot) statsReport(ctx context.Context, chatID int64) (string, error) {
+	stats, err := b.collectStats(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	var report strings.Builder
+	report.WriteString("Статистика анкет")
+	if name := b.community(chatID).Name; name != "" {
+		report.WriteString(" сообщества «" + name + "»")
+	}
+	report.WriteString("\n\n")
+	fmt.Fprintf(&report, "Всего анкет: %d\n", stats.Profiles)
+	fmt.Fprintf(&report, "Водителей: %d, пассажиров: %d\n", stats.Drivers, stats.Passengers)
+	fmt.Fprintf(&report, "Опубликовано: %d, скрыто: %d\n", stats.Active, stats.Hidden)
+	fmt.Fprintf(&report, "Создано за неделю: %d\n", stats.CreatedWeek)
+	if rate, ok := stats.completionRate(); ok {
+		fmt.Fprintf(&report, "Заполнение анкеты завершают: %.0f%% (завершено: %d, брошено: %d)\n", rate, stats.Completed, stats.Abandoned)
+	}
+	fmt.Fprintf(&report, "Сейчас заполняются: %d\n", stats.Unfinished)
+	report.WriteString("\nВозраст:\n")
+	for i, group := range ageGroups {
+		fmt.Fprintf(&report, "%s: %d\n", group.label, stats.Ages[i])
+	}
+	return report.String(), nil
+}
+
+// Stats отправляет администратору в личные сообщения статистику анкет сообщества.
+func (b *MotoBot) Stats(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	report, err := b.statsReport(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	_, err = b.send(ctx, tgbotapi.NewMessage(int64(message.From.ID), report))
+	return err
+}
+
+// postStatsReport отправляет плановый отчёт по всем сообществам в чат администраторов.
+func (b *MotoBot) postStatsReport(ctx context.Context) {
+	if b.settings.StatsChatID == 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+	for _, chatID := range b.communityOrder {
+		report, err := b.statsReport(ctx, chatID)
+		if err != nil {
+			logger.Error("Ошибка при подготовке отчёта со статистикой", "error", err, "chat_id", chatID)
+			continue
+		}
+		b.post(ctx, tgbotapi.NewMessage(b.settings.StatsChatID, report))
+	}
+}
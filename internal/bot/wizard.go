@@ -0,0 +1,719 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/t1ery/MotoBot/internal/logging"
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/storage"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// defaultSessionTimeout - время бездействия, после которого незавершённая анкета удаляется
+const defaultSessionTimeout = 30 * time.Minute
+
+// Данные инлайн кнопок мастера заполнения анкеты
+const (
+	callbackWizardContinue = "wizard_continue"
+	callbackWizardRestart  = "wizard_restart"
+	callbackWizardBack     = "wizard_back"
+	callbackWizardSkip     = "wizard_skip"
+	callbackWizardPublish  = "wizard_publish"
+	callbackWizardChange   = "wizard_change"
+	callbackWizardCancel   = "wizard_cancel"
+	callbackFinishEditing  = "finish_editing"
+	callbackIsDriverYes    = "is_driver_yes"
+	callbackIsDriverNo     = "is_driver_no"
+)
+
+// fieldButton - кнопка выбора поля анкеты для изменения
+type fieldButton struct {
+	label string // Надпись на кнопке
+	data  string // Данные инлайн кнопки
+	step  int    // Шаг анкеты, на котором задаётся поле
+}
+
+// fieldButtons - поля анкеты, которые можно изменить перед публикацией и при редактировании опубликованной анкеты
+var fieldButtons = []fieldButton{
+	{label: "Имя", data: "edit_name", step: user.StepFirstName},
+	{label: "Фамилия", data: "edit_last_name", step: user.StepLastName},
+	{label: "Возраст", data: "edit_age", step: user.StepAge},
+	{label: "Город", data: "edit_city", step: user.StepCity},
+	{label: "Водитель", data: "edit_is_driver", step: user.StepIsDriver},
+	{label: "Интересы", data: "edit_interests", step: user.StepInterests},
+	{label: "Фотография", data: "edit_photo", step: user.StepPhoto},
+	{label: "Контакты", data: "edit_contacts", step: user.StepContacts},
+}
+
+// CreateProfile начинает заполнение анкеты. Если у пользователя есть незавершённая анкета,
+// бот предлагает продолжить её или начать заново.
+func (b *MotoBot) CreateProfile(ctx context.Context, userID int, chatID int64) error {
+	// Получаем профиль пользователя из хранилища
+	_, err := b.dataStorage.GetProfile(ctx, chatID, userID)
+	if err == nil {
+		message := tgbotapi.NewMessage(int64(userID), "Вы уже создали анкету.")
+		_, err := b.send(ctx, message)
+		return err
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	session, err := b.activeSession(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if session != nil {
+		inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Продолжить", callbackWizardContinue),
+				tgbotapi.NewInlineKeyboardButtonData("Начать заново", callbackWizardRestart),
+			),
+		)
+		text := fmt.Sprintf("У вас есть незаполненная анкета (шаг %d из %d). Продолжить или начать заново?", min(session.Step+1, user.StepPreview), user.StepPreview)
+		message := tgbotapi.NewMessage(int64(userID), text)
+		message.ReplyMarkup = inlineKeyboard
+		_, err := b.send(ctx, message)
+		return err
+	}
+
+	session = &user.Session{
+		UserID:  userID,
+		ChatID:  chatID,
+		Step:    user.StepFirstName,
+		Profile: user.Profile{UserID: userID},
+	}
+	reachStep(session)
+
+	err = b.touchSession(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), "Начинаем заполнение анкеты. Отменить его можно в любой момент командой /cancel.")
+	_, err = b.send(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	return b.sendStepPrompt(ctx, session)
+}
+
+// CancelProfile прерывает заполнение анкеты и удаляет введённые данные.
+func (b *MotoBot) CancelProfile(ctx context.Context, userID int) error {
+	session, err := b.activeSession(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	text := "Сейчас вы не заполняете анкету."
+	if session != nil {
+		err = b.dataStorage.DeleteSession(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if session.Editing {
+			text = "Редактирование отменено, анкета осталась без изменений."
+		} else {
+			metrics.WizardAbandoned.WithLabelValues(user.StepName(session.Step)).Inc()
+			b.recordWizardOutcome(ctx, session.ChatID, false)
+			text = "Заполнение анкеты отменено. Чтобы начать заново, отправьте /start."
+		}
+	}
+
+	message := tgbotapi.NewMessage(int64(userID), text)
+	_, err = b.send(ctx, message)
+	return err
+}
+
+// wizardSession возвращает незавершённую анкету, если обновление является ответом на её шаг.
+// Команды в мастер не передаются, чтобы /cancel, /info и другие работали на любом шаге.
+func (b *MotoBot) wizardSession(ctx context.Context, update tgbotapi.Update) (*user.Session, error) {
+	var userID int
+	switch {
+	case update.Message != nil && update.Message.Chat != nil && update.Message.Chat.IsPrivate() && !update.Message.IsCommand():
+		userID = update.Message.From.ID
+	case update.CallbackQuery != nil && isWizardCallback(update.CallbackQuery.Data):
+		userID = update.CallbackQuery.From.ID
+	default:
+		return nil, nil
+	}
+
+	return b.activeSession(ctx, userID)
+}
+
+// isWizardCallback сообщает, относится ли инлайн кнопка к мастеру заполнения анкеты.
+func isWizardCallback(data string) bool {
+	switch data {
+	case callbackWizardContinue, callbackWizardRestart, callbackWizardBack, callbackWizardSkip,
+		callbackWizardPublish, callbackWizardChange, callbackWizardCancel, callbackFinishEditing,
+		callbackIsDriverYes, callbackIsDriverNo:
+		return true
+	}
+
+	for _, button := range fieldButtons {
+		if button.data == data {
+			return true
+		}
+	}
+	return false
+}
+
+// handleWizardUpdate принимает ответ на текущий шаг анкеты и переходит к следующему.
+func (b *MotoBot) handleWizardUpdate(ctx context.Context, session *user.Session, update tgbotapi.Update) error {
+	if update.CallbackQuery != nil {
+		b.answerCallback(ctx, update.CallbackQuery.ID)
+
+		data := update.CallbackQuery.Data
+		switch data {
+		case callbackWizardContinue:
+			return b.sendStepPrompt(ctx, session)
+		case callbackWizardRestart:
+			session.Step = user.StepFirstName
+			session.Furthest = user.StepFirstName
+			session.Profile = user.Profile{UserID: session.UserID}
+			reachStep(session)
+			err := b.touchSession(ctx, session)
+			if err != nil {
+				return err
+			}
+			return b.sendStepPrompt(ctx, session)
+		case callbackWizardBack:
+			// Возвращаемся к предыдущему вопросу, введённый ранее ответ будет показан в подсказке.
+			// Если поле меняли из предпросмотра, возвращаемся к предпросмотру
+			if session.Review {
+				session.Step = user.StepPreview
+			} else if session.Step > user.StepFirstName {
+				// Вопросы, которых нет в анкете сообщества, пропускаются
+				community := b.community(session.ChatID)
+				session.Step--
+				for session.Step > user.StepFirstName && !community.asksStep(session.Step) {
+					session.Step--
+				}
+			}
+			err := b.touchSession(ctx, session)
+			if err != nil {
+				return err
+			}
+			return b.sendStepPrompt(ctx, session)
+		case callbackWizardSkip:
+			if !user.IsOptionalStep(session.Step) {
+				return b.sendStepPrompt(ctx, session)
+			}
+			switch session.Step {
+			case user.StepPhoto:
+				session.Profile.Photo = nil
+				session.Profile.PhotoFileID = ""
+			case user.StepCity:
+				session.Profile.City = ""
+			case user.StepContacts:
+				session.Profile.Contacts = ""
+			}
+			return b.nextStep(ctx, session)
+		case callbackIsDriverYes, callbackIsDriverNo:
+			// Кнопки старого сообщения с вопросом могли нажать уже на другом шаге
+			if session.Step != user.StepIsDriver {
+				return b.sendStepPrompt(ctx, session)
+			}
+			session.Profile.IsDriver = data == callbackIsDriverYes
+			return b.nextStep(ctx, session)
+		case callbackWizardPublish:
+			if session.Step != user.StepPreview {
+				return b.sendStepPrompt(ctx, session)
+			}
+			if session.Editing {
+				return b.publishEdits(ctx, session)
+			}
+			return b.completeProfile(ctx, session)
+		case callbackWizardChange:
+			return b.sendFieldChooser(ctx, session)
+		case callbackWizardCancel:
+			return b.CancelProfile(ctx, session.UserID)
+		case callbackFinishEditing:
+			session.Step = user.StepPreview
+			err := b.touchSession(ctx, session)
+			if err != nil {
+				return err
+			}
+			return b.sendStepPrompt(ctx, session)
+		}
+
+		for _, button := range fieldButtons {
+			if button.data != data {
+				continue
+			}
+			// После ответа на выбранный вопрос пользователь вернётся к предпросмотру
+			session.Step = button.step
+			session.Review = true
+			err := b.touchSession(ctx, session)
+			if err != nil {
+				return err
+			}
+			return b.sendStepPrompt(ctx, session)
+		}
+		return nil
+	}
+
+	hint, err := b.applyAnswer(ctx, session, update.Message)
+	if err != nil {
+		return err
+	}
+	if hint != "" {
+		// Ответ не подходит для текущего шага - объясняем, что нужно, и повторяем вопрос
+		message := tgbotapi.NewMessage(int64(session.UserID), hint)
+		_, err := b.send(ctx, message)
+		if err != nil {
+			return err
+		}
+		return b.sendStepPrompt(ctx, session)
+	}
+
+	return b.nextStep(ctx, session)
+}
+
+// applyAnswer записывает ответ пользователя в анкету. Если ответ не подходит для шага,
+// возвращает подсказку для пользователя.
+func (b *MotoBot) applyAnswer(ctx context.Context, session *user.Session, message *tgbotapi.Message) (string, error) {
+	profile := &session.Profile
+	text := strings.TrimSpace(message.Text)
+
+	if session.Step == user.StepPreview {
+		return "Опубликуйте анкету или выберите действие кнопками под ней.", nil
+	}
+
+	if session.Step == user.StepPhoto {
+		// Проверяем, есть ли фотографии в сообщении
+		if message.Photo == nil || len(*message.Photo) == 0 {
+			return "На данном шаге необходимо загрузить фотографию.", nil
+		}
+
+		photoBytes, err := b.downloadLargestPhoto(ctx, *message.Photo)
+		if err != nil {
+			logging.FromContext(ctx).Error("Ошибка при загрузке файла фотографии", "error", err)
+			return "Не удалось получить фотографию, попробуйте отправить её ещё раз.", nil
+		}
+
+		// Добавляем фотографию в структуру пользователя
+		profile.Photo = photoBytes
+		profile.PhotoFileID = largestPhoto(*message.Photo).FileID
+		logging.FromContext(ctx).Debug("Сохранена фотография", "size", len(photoBytes))
+		return "", nil
+	}
+
+	if text == "" {
+		return "Пожалуйста, ответьте текстовым сообщением.", nil
+	}
+
+	switch session.Step {
+	case user.StepFirstName:
+		profile.FirstName = text
+	case user.StepLastName:
+		profile.LastName = text
+	case user.StepAge:
+		age, err := strconv.Atoi(text)
+		if err != nil || age <= 0 {
+			return "Возраст нужно указать числом, например: 25.", nil
+		}
+		profile.Age = age
+	case user.StepCity:
+		profile.City = text
+	case user.StepIsDriver:
+		switch text {
+		case "Да":
+			profile.IsDriver = true
+		case "Нет":
+			profile.IsDriver = false
+		default:
+			return "Выберите ответ кнопкой «Да» или «Нет».", nil
+		}
+	case user.StepInterests:
+		profile.Interests = text
+	case user.StepContacts:
+		profile.Contacts = text
+	}
+
+	return "", nil
+}
+
+// nextStep сохраняет ответ и задаёт следующий вопрос, а после последнего шага показывает предпросмотр анкеты.
+func (b *MotoBot) nextStep(ctx context.Context, session *user.Session) error {
+	switch {
+	case session.Review && session.Step == user.StepIsDriver:
+		// Пожелания зависят от роли, поэтому после её смены вопрос задаётся заново
+		session.Step = user.StepInterests
+	case session.Review:
+		session.Step = user.StepPreview
+	default:
+		// Вопросы, которых нет в анкете сообщества, пропускаются
+		community := b.community(session.ChatID)
+		session.Step++
+		for session.Step < user.StepPreview && !community.asksStep(session.Step) {
+			session.Step++
+		}
+		reachStep(session)
+	}
+
+	// Дойдя до предпросмотра, пользователь меняет поля по одному и возвращается к нему
+	if session.Step == user.StepPreview {
+		session.Review = true
+	}
+
+	err := b.touchSession(ctx, session)
+	if err != nil {
+		return err
+	}
+	return b.sendStepPrompt(ctx, session)
+}
+
+// completeProfile сохраняет заполненную анкету, отправляет её в группу и удаляет сессию.
+func (b *MotoBot) completeProfile(ctx context.Context, session *user.Session) error {
+	profile := session.Profile
+	profile.ChatID = session.ChatID
+	profile.CreatedAt = time.Now()
+	profile.UpdatedAt = profile.CreatedAt
+
+	// Сохраняем профиль в хранилище
+	err := b.dataStorage.SaveProfile(ctx, &profile)
+	if err != nil {
+		return err
+	}
+	metrics.WizardCompleted.Inc()
+	b.recordWizardOutcome(ctx, session.ChatID, true)
+	metrics.ProfileEvents.WithLabelValues(metrics.ProfileCreated).Inc()
+	b.auditProfile(ctx, &profile, session.UserID, user.AuditCreated, "")
+
+	// Анкета заполнена полностью, незавершённая сессия больше не нужна
+	err = b.dataStorage.DeleteSession(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	// После завершения всех шагов, отправляем анкету в группу
+	err = b.SendProfile(ctx, session.UserID, session.ChatID, &profile)
+	if err != nil {
+		return err
+	}
+
+	// Отправляем сообщение об успешном создании анкеты
+	message := tgbotapi.NewMessage(int64(session.UserID), "Ваша анкета успешно создана и отправлена в группу.")
+	_, err = b.send(ctx, message)
+	return err
+}
+
+// publishEdits заменяет анкету в группе отредактированной и удаляет сессию. Сессия хранит копию анкеты
+// на момент начала редактирования, а пока оно шло, анкету могли скрыть, поднять или снять после выхода
+// участника из группы. Поэтому из сессии берутся только ответы, а остальное - из хранилища.
+func (b *MotoBot) publishEdits(ctx context.Context, session *user.Session) error {
+	// Опубликованная анкета нужна, чтобы записать изменения в журнал и понять,
+	// можно ли изменить сообщение в группе на месте
+	published, err := b.dataStorage.GetProfile(ctx, session.ChatID, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	profile := *published
+	profile.CopyAnswers(&session.Profile)
+	profile.UpdatedAt = time.Now()
+	profile.AskedAt = time.Time{}
+
+	edited := user.AuditEntry{
+		ChatID:  profile.ChatID,
+		UserID:  session.UserID,
+		ActorID: session.UserID,
+		Action:  user.AuditEdited,
+		Changes: user.DiffProfiles(published, &profile),
+	}
+
+	// Скрытая анкета и анкета участника, вышедшего из группы, не возвращаются в группу
+	// при редактировании, изменения только сохраняются
+	if profile.Hidden || !profile.LeftAt.IsZero() {
+		err := b.dataStorage.SaveProfile(ctx, &profile)
+		if err != nil {
+			return err
+		}
+		metrics.ProfileEvents.WithLabelValues(metrics.ProfileEdited).Inc()
+		b.audit(ctx, edited)
+
+		err = b.dataStorage.DeleteSession(ctx, session.UserID)
+		if err != nil {
+			return err
+		}
+
+		text := "Изменения сохранены. Анкета скрыта, чтобы вернуть её в группу, отправьте /show."
+		if !profile.Hidden {
+			text = "Изменения сохранены. Вы вышли из группы, поэтому анкета в ней не обновлена."
+		}
+		message := tgbotapi.NewMessage(int64(session.UserID), text)
+		_, err = b.send(ctx, message)
+		return err
+	}
+
+	// Обновление анкеты в группе, вместе с ней профиль сохраняется в хранилище
+	err = b.updateProfilePost(ctx, published, &profile)
+	if err != nil {
+		return err
+	}
+	metrics.ProfileEvents.WithLabelValues(metrics.ProfileEdited).Inc()
+	b.audit(ctx, edited)
+
+	err = b.dataStorage.DeleteSession(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Завершение редактирования
+	message := tgbotapi.NewMessage(int64(session.UserID), "Редактирование завершено.")
+	_, err = b.send(ctx, message)
+	return err
+}
+
+// sendPreview показывает пользователю анкету в том виде, в котором она будет опубликована в группе.
+func (b *MotoBot) sendPreview(ctx context.Context, session *user.Session) error {
+	userID := int64(session.UserID)
+
+	message := tgbotapi.NewMessage(userID, "Так анкета будет выглядеть в группе. Проверьте её перед публикацией:")
+	_, err := b.send(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Опубликовать", callbackWizardPublish),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Изменить поле…", callbackWizardChange),
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", callbackWizardCancel),
+		),
+	)
+
+	caption := b.profileCaption(session.UserID, session.ChatID, &session.Profile)
+	_, err = b.send(ctx, profileMessage(userID, caption, &session.Profile, inlineKeyboard))
+	return err
+}
+
+// sendFieldChooser отправляет инлайн клавиатуру для выбора поля анкеты, которое нужно изменить.
+func (b *MotoBot) sendFieldChooser(ctx context.Context, session *user.Session) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	community := b.community(session.ChatID)
+	for _, button := range fieldButtons {
+		if !community.asksStep(button.step) {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(button.label, button.data))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Завершить редактирование", callbackFinishEditing),
+	))
+
+	message := tgbotapi.NewMessage(int64(session.UserID), "Выберите раздел анкеты для редактирования:")
+	message.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err := b.send(ctx, message)
+	return err
+}
+
+// sendStepPrompt отправляет вопрос текущего шага анкеты.
+func (b *MotoBot) sendStepPrompt(ctx context.Context, session *user.Session) error {
+	userID := int64(session.UserID)
+
+	var message tgbotapi.MessageConfig
+	switch session.Step {
+	case user.StepFirstName:
+		message = tgbotapi.NewMessage(userID, "Введите ваше имя:")
+	case user.StepLastName:
+		message = tgbotapi.NewMessage(userID, "Введите вашу фамилию:")
+	case user.StepAge:
+		message = tgbotapi.NewMessage(userID, "Введите ваш возраст:")
+	case user.StepCity:
+		message = tgbotapi.NewMessage(userID, "Укажите по желанию ваш город:")
+	case user.StepIsDriver:
+		inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Да", callbackIsDriverYes),
+				tgbotapi.NewInlineKeyboardButtonData("Нет", callbackIsDriverNo),
+			),
+		)
+		message = tgbotapi.NewMessage(userID, "Вы являетесь водителем?")
+		message.ReplyMarkup = inlineKeyboard
+	case user.StepInterests:
+		var messageText string
+		if session.Profile.IsDriver {
+			messageText = "Какие у вас будут пожелания к пассажиру?"
+		} else {
+			messageText = "Какие у вас будут пожелания к водителю?"
+		}
+		message = tgbotapi.NewMessage(userID, messageText)
+	case user.StepPhoto:
+		message = tgbotapi.NewMessage(userID, "Загрузите фотографию на ваш выбор:")
+	case user.StepContacts:
+		message = tgbotapi.NewMessage(userID, "Укажите по желанию контакты для связи с вами, например - номер телефона:")
+	case user.StepPreview:
+		return b.sendPreview(ctx, session)
+	default:
+		return fmt.Errorf("неизвестный шаг анкеты: %d", session.Step)
+	}
+
+	// При создании анкеты показываем номер шага, при изменении поля - что идёт редактирование
+	if session.Review {
+		message.Text = "Редактирование: " + message.Text
+	} else {
+		message.Text = fmt.Sprintf("Шаг %d: %s", session.Step+1, message.Text)
+	}
+
+	// Если пользователь вернулся к уже отвеченному вопросу, напоминаем прежний ответ
+	if hint := previousAnswer(session); hint != "" {
+		message.Text += "\nСейчас указано: " + hint
+	}
+
+	// Кнопки навигации добавляются к кнопкам самого шага
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if keyboard, ok := message.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+		rows = keyboard.InlineKeyboard
+	}
+	var navigation []tgbotapi.InlineKeyboardButton
+	if session.Step > user.StepFirstName || session.Review {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀ Назад", callbackWizardBack))
+	}
+	if user.IsOptionalStep(session.Step) {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("Пропустить", callbackWizardSkip))
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	if len(rows) > 0 {
+		message.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	_, err := b.send(ctx, message)
+	return err
+}
+
+// previousAnswer возвращает уже введённый ответ на текущий шаг или пустую строку, если его нет.
+func previousAnswer(session *user.Session) string {
+	if session.Step >= session.Furthest {
+		return ""
+	}
+
+	profile := session.Profile
+	switch session.Step {
+	case user.StepFirstName:
+		return profile.FirstName
+	case user.StepLastName:
+		return profile.LastName
+	case user.StepAge:
+		if profile.Age > 0 {
+			return strconv.Itoa(profile.Age)
+		}
+	case user.StepCity:
+		return profile.City
+	case user.StepIsDriver:
+		if profile.IsDriver {
+			return "Да"
+		}
+		return "Нет"
+	case user.StepInterests:
+		return profile.Interests
+	case user.StepPhoto:
+		if len(profile.Photo) > 0 {
+			return "фотография загружена"
+		}
+	case user.StepContacts:
+		return profile.Contacts
+	}
+	return ""
+}
+
+// reachStep учитывает шаг в воронке заполнения анкеты, если пользователь дошёл до него впервые.
+func reachStep(session *user.Session) {
+	if session.Step < session.Furthest {
+		return
+	}
+	if session.Step > session.Furthest || session.Step == user.StepFirstName {
+		metrics.WizardSteps.WithLabelValues(user.StepName(session.Step)).Inc()
+	}
+	session.Furthest = session.Step
+}
+
+// touchSession сохраняет сессию, отмечая время последнего ответа.
+func (b *MotoBot) touchSession(ctx context.Context, session *user.Session) error {
+	session.UpdatedAt = time.Now()
+	return b.dataStorage.SaveSession(ctx, session)
+}
+
+// activeSession возвращает незавершённую анкету пользователя или nil, если её нет.
+// Истёкшая сессия удаляется, а пользователь получает уведомление.
+func (b *MotoBot) activeSession(ctx context.Context, userID int) (*user.Session, error) {
+	session, err := b.dataStorage.GetSession(ctx, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Незавершённой анкеты нет
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if b.sessionExpired(session) {
+		return nil, b.expireSession(ctx, session)
+	}
+	return session, nil
+}
+
+// sessionExpired сообщает, превысило ли время бездействия допустимое.
+func (b *MotoBot) sessionExpired(session *user.Session) bool {
+	return time.Since(session.UpdatedAt) > b.settings.SessionTimeout
+}
+
+// expireSession удаляет брошенную анкету и сообщает об этом пользователю.
+func (b *MotoBot) expireSession(ctx context.Context, session *user.Session) error {
+	err := b.dataStorage.DeleteSession(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	text := "Время редактирования анкеты истекло, изменения не сохранены."
+	if !session.Editing {
+		metrics.WizardAbandoned.WithLabelValues(user.StepName(session.Step)).Inc()
+		b.recordWizardOutcome(ctx, session.ChatID, false)
+		text = "Время заполнения анкеты истекло, введённые данные удалены. Чтобы начать заново, отправьте /start."
+	}
+
+	message := tgbotapi.NewMessage(int64(session.UserID), text)
+	b.post(ctx, message)
+	return nil
+}
+
+// expireSessions удаляет все анкеты, заполнение которых было брошено.
+func (b *MotoBot) expireSessions(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	sessions, err := b.dataStorage.ListSessions(ctx)
+	if err != nil {
+		logger.Error("Ошибка при получении незавершённых анкет", "error", err)
+		return
+	}
+
+	for _, session := range sessions {
+		if !b.sessionExpired(session) {
+			continue
+		}
+		err := b.expireSession(ctx, session)
+		if err != nil {
+			logger.Error("Ошибка при удалении брошенной анкеты", "error", err, "user_id", session.UserID)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "motobot"
+
+// События жизненного цикла анкеты
+const (
+	ProfileCreated = "created"
+	ProfileEdited  = "edited"
+	ProfileDeleted = "deleted"
+)
+
+var (
+	// UpdatesProcessed - количество обработанных обновлений по типу
+	UpdatesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_processed_total",
+		Help:      "Количество обработанных обновлений Telegram по типу.",
+	}, []string{"type"})
+
+	// CommandsInvoked - количество вызовов команд бота
+	CommandsInvoked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_invoked_total",
+		Help:      "Количество вызовов команд бота.",
+	}, []string{"command"})
+
+	// ProfileEvents - количество созданных, отредактированных и удалённых анкет
+	ProfileEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "profile_events_total",
+		Help:      "Количество созданных, отредактированных и удалённых анкет.",
+	}, []string{"event"})
+
+	// WizardSteps - сколько раз пользователи доходили до шага анкеты
+	WizardSteps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wizard_steps_reached_total",
+		Help:      "Сколько раз пользователи доходили до шага заполнения анкеты.",
+	}, []string{"step"})
+
+	// WizardCompleted - количество полностью заполненных анкет
+	WizardCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wizard_completed_total",
+		Help:      "Количество полностью заполненных анкет.",
+	})
+
+	// WizardAbandoned - количество брошенных заполнений анкеты по шагу, на котором это произошло
+	WizardAbandoned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "wizard_abandoned_total",
+		Help:      "Количество брошенных заполнений анкеты по шагу.",
+	}, []string{"step"})
+
+	// TelegramRequestDuration - время выполнения запросов к Telegram API
+	TelegramRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "telegram_request_duration_seconds",
+		Help:      "Время выполнения запросов к Telegram API.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// TelegramErrors - количество ошибок запросов к Telegram API
+	TelegramErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_errors_total",
+		Help:      "Количество ошибок запросов к Telegram API.",
+	}, []string{"method"})
+
+	// StorageDuration - время выполнения операций с хранилищем
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Время выполнения операций с хранилищем.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	}, []string{"operation", "status"})
+)
+
+// ObserveTelegram учитывает время и результат запроса к Telegram API.
+func ObserveTelegram(method string, start time.Time, err error) {
+	TelegramRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		TelegramErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveStorage учитывает время и результат операции с хранилищем.
+func ObserveStorage(operation string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	StorageDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+// Serve запускает HTTP-сервер с эндпоинтом /metrics и останавливает его при отмене контекста.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// BackupVersion - версия формата архива резервной копии. Увеличивается при несовместимых изменениях формата
+const BackupVersion = 4
+
+// Файлы внутри архива резервной копии
+const (
+	backupManifestFile = "manifest.json"
+	backupProfilesFile = "profiles.json"
+	backupSessionsFile = "sessions.json"
+	backupGrantsFile   = "grants.json"
+	backupAuditFile    = "audit.json"  // Появился во второй версии
+	backupWizardFile   = "wizard.json" // Появился в четвёртой версии
+	backupPhotosDir    = "photos/"
+)
+
+// BackupManifest - описание архива резервной копии
+type BackupManifest struct {
+	Version   int       `json:"version"`    // Версия формата архива
+	CreatedAt time.Time `json:"created_at"` // Время создания резервной копии
+	Profiles  int       `json:"profiles"`   // Количество анкет
+	Photos    int       `json:"photos"`     // Количество фотографий
+	Sessions  int       `json:"sessions"`   // Количество незавершённых заполнений анкет
+	Grants    int       `json:"grants"`     // Количество выданных ролей
+	Audit     int       `json:"audit"`      // Количество записей журнала аудита
+	Wizard    int       `json:"wizard"`     // Количество групп с итогами заполнения анкеты
+}
+
+// Backup записывает все данные хранилища в ZIP архив. Фотографии анкет хранятся отдельными файлами
+// photos/<chatID>_<userID>.jpg, остальные данные - в JSON файлах.
+func Backup(ctx context.Context, s Storage, w io.Writer) (BackupManifest, error) {
+	manifest := BackupManifest{Version: BackupVersion, CreatedAt: time.Now()}
+
+	profiles, err := s.ListProfiles(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение анкет: %w", err)
+	}
+	sessions, err := s.ListSessions(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение сессий: %w", err)
+	}
+	grants, err := s.ListGrants(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение ролей: %w", err)
+	}
+	audit, err := s.ListAudit(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение журнала аудита: %w", err)
+	}
+	wizard, err := s.ListWizardStats(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение итогов заполнения анкеты: %w", err)
+	}
+
+	archive := zip.NewWriter(w)
+
+	// Фотографии не дублируются в JSON, чтобы архив оставался читаемым
+	stripped := make([]user.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if len(profile.Photo) > 0 {
+			err = writeBackupFile(archive, backupPhotoPath(profile, BackupVersion), profile.Photo)
+			if err != nil {
+				return manifest, err
+			}
+			manifest.Photos++
+		}
+		copied := *profile
+		copied.Photo = nil
+		stripped = append(stripped, copied)
+	}
+
+	manifest.Profiles = len(profiles)
+	manifest.Sessions = len(sessions)
+	manifest.Grants = len(grants)
+	manifest.Audit = len(audit)
+	manifest.Wizard = len(wizard)
+
+	files := []struct {
+		name  string
+		value any
+	}{
+		{backupManifestFile, manifest},
+		{backupProfilesFile, stripped},
+		{backupSessionsFile, sessions},
+		{backupGrantsFile, grants},
+		{backupAuditFile, audit},
+		{backupWizardFile, wizard},
+	}
+	for _, file := range files {
+		err = writeBackupJSON(archive, file.name, file.value)
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	return manifest, archive.Close()
+}
+
+// backupPhotoPath возвращает имя файла фотографии анкеты в архиве. До третьей версии у пользователя
+// была одна анкета, и фотографии назывались только по его идентификатору.
+func backupPhotoPath(profile *user.Profile, version int) string {
+	if version < 3 {
+		return backupPhotosDir + strconv.Itoa(profile.UserID) + ".jpg"
+	}
+	return backupPhotosDir + strconv.FormatInt(profile.ChatID, 10) + "_" + strconv.Itoa(profile.UserID) + ".jpg"
+}
+
+// writeBackupJSON записывает значение в архив файлом JSON.
+func writeBackupJSON(archive *zip.Writer, name string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("кодирование %s: %w", name, err)
+	}
+	return writeBackupFile(archive, name, data)
+}
+
+// writeBackupFile записывает файл в архив.
+func writeBackupFile(archive *zip.Writer, name string, data []byte) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("запись %s: %w", name, err)
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		return fmt.Errorf("запись %s: %w", name, err)
+	}
+	return nil
+}
+
+// Restore загружает в хранилище данные из архива резервной копии. Записи с теми же ключами
+// перезаписываются, записи журнала аудита, которые уже есть в хранилище, пропускаются, поэтому
+// повторное восстановление того же архива ничего не дублирует. Остальные данные хранилища не меняются.
+func Restore(ctx context.Context, s Storage, r io.ReaderAt, size int64) (BackupManifest, error) {
+	var manifest BackupManifest
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return manifest, fmt.Errorf("чтение архива: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	err = readBackupJSON(files, backupManifestFile, &manifest)
+	if err != nil {
+		return manifest, err
+	}
+	if manifest.Version < 1 || manifest.Version > BackupVersion {
+		return manifest, fmt.Errorf("неподдерживаемая версия архива %d", manifest.Version)
+	}
+
+	var profiles []*user.Profile
+	var sessions []*user.Session
+	var grants []*user.Grant
+	var audit []*user.AuditEntry
+	var wizard []*user.WizardStats
+	for name, value := range map[string]any{
+		backupProfilesFile: &profiles,
+		backupSessionsFile: &sessions,
+		backupGrantsFile:   &grants,
+	} {
+		err = readBackupJSON(files, name, value)
+		if err != nil {
+			return manifest, err
+		}
+	}
+	if manifest.Version >= 2 {
+		err = readBackupJSON(files, backupAuditFile, &audit)
+		if err != nil {
+			return manifest, err
+		}
+	}
+	if manifest.Version >= 4 {
+		err = readBackupJSON(files, backupWizardFile, &wizard)
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	for _, profile := range profiles {
+		if file, found := files[backupPhotoPath(profile, manifest.Version)]; found {
+			profile.Photo, err = readBackupFile(file)
+			if err != nil {
+				return manifest, err
+			}
+		}
+		err = s.SaveProfile(ctx, profile)
+		if err != nil {
+			return manifest, fmt.Errorf("сохранение анкеты %d: %w", profile.UserID, err)
+		}
+	}
+	for _, session := range sessions {
+		err = s.SaveSession(ctx, session)
+		if err != nil {
+			return manifest, fmt.Errorf("сохранение сессии %d: %w", session.UserID, err)
+		}
+	}
+	for _, grant := range grants {
+		err = s.SaveGrant(ctx, grant)
+		if err != nil {
+			return manifest, fmt.Errorf("сохранение роли %d: %w", grant.UserID, err)
+		}
+	}
+	existing, err := s.ListAudit(ctx)
+	if err != nil {
+		return manifest, fmt.Errorf("получение журнала аудита: %w", err)
+	}
+	known := make(map[auditKey]bool, len(existing))
+	for _, entry := range existing {
+		known[newAuditKey(entry)] = true
+	}
+	for _, entry := range audit {
+		key := newAuditKey(entry)
+		if known[key] {
+			continue
+		}
+		known[key] = true
+		err = s.AppendAudit(ctx, entry)
+		if err != nil {
+			return manifest, fmt.Errorf("сохранение записи журнала аудита %d: %w", entry.UserID, err)
+		}
+	}
+	for _, stats := range wizard {
+		err = s.SaveWizardStats(ctx, stats)
+		if err != nil {
+			return manifest, fmt.Errorf("сохранение итогов заполнения анкеты %d: %w", stats.ChatID, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// auditKey - то, по чему запись журнала аудита из архива сравнивается с записями в хранилище.
+// У записей нет идентификатора, но действие над пользователем не повторяется в ту же наносекунду.
+type auditKey struct {
+	chatID    int64
+	userID    int
+	actorID   int
+	action    string
+	createdAt int64
+}
+
+func newAuditKey(entry *user.AuditEntry) auditKey {
+	return auditKey{
+		chatID:    entry.ChatID,
+		userID:    entry.UserID,
+		actorID:   entry.ActorID,
+		action:    entry.Action,
+		createdAt: entry.CreatedAt.UnixNano(),
+	}
+}
+
+// readBackupJSON читает файл JSON из архива.
+func readBackupJSON(files map[string]*zip.File, name string, value any) error {
+	file, found := files[name]
+	if !found {
+		return fmt.Errorf("в архиве нет файла %s", name)
+	}
+	data, err := readBackupFile(file)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		return fmt.Errorf("разбор %s: %w", name, err)
+	}
+	return nil
+}
+
+// readBackupFile читает файл из архива.
+func readBackupFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("чтение %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("чтение %s: %w", file.Name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// fillStorage заполняет хранилище данными всех видов, которые попадают в резервную копию.
+func fillStorage(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	profiles := []*user.Profile{
+		{UserID: 1, ChatID: -100, FirstName: "Иван", Age: 30, City: "Москва", Photo: []byte("photo-1"), IsDriver: true, MessageID: 10, CreatedAt: created},
+		{UserID: 1, ChatID: -200, FirstName: "Иван", Age: 30, Hidden: true, CreatedAt: created},
+		{UserID: 2, ChatID: -100, FirstName: "Анна", Age: 25, Photo: []byte("photo-2"), CreatedAt: created},
+	}
+	for _, profile := range profiles {
+		if err := s.SaveProfile(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveSession(ctx, &user.Session{UserID: 3, ChatID: -100, Step: 2, Profile: user.Profile{FirstName: "Пётр"}, UpdatedAt: created}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveGrant(ctx, &user.Grant{ChatID: -100, UserID: 2, Role: user.RoleOrganizer, GrantedBy: 1, GrantedAt: created}); err != nil {
+		t.Fatal(err)
+	}
+	entries := []*user.AuditEntry{
+		{ChatID: -100, UserID: 1, ActorID: 1, Action: user.AuditCreated, CreatedAt: created},
+		{ChatID: -100, UserID: 1, ActorID: 1, Action: user.AuditEdited, Changes: []user.FieldChange{{Field: "age", Old: "29", New: "30"}}, CreatedAt: created.Add(time.Hour)},
+	}
+	for _, entry := range entries {
+		if err := s.AppendAudit(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveWizardStats(ctx, &user.WizardStats{ChatID: -100, Completed: 3, Abandoned: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// snapshot - все данные хранилища в порядке, не зависящем от реализации
+type snapshot struct {
+	Profiles []*user.Profile
+	Sessions []*user.Session
+	Grants   []*user.Grant
+	Audit    []*user.AuditEntry
+	Wizard   []*user.WizardStats
+}
+
+func takeSnapshot(t *testing.T, s Storage) snapshot {
+	t.Helper()
+	ctx := context.Background()
+
+	var snap snapshot
+	var err error
+	if snap.Profiles, err = s.ListProfiles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Sessions, err = s.ListSessions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Grants, err = s.ListGrants(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Audit, err = s.ListAudit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Wizard, err = s.ListWizardStats(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(snap.Profiles, func(i, j int) bool { return profileBefore(snap.Profiles[i], snap.Profiles[j]) })
+	sort.Slice(snap.Sessions, func(i, j int) bool { return snap.Sessions[i].UserID < snap.Sessions[j].UserID })
+	sort.Slice(snap.Grants, func(i, j int) bool { return snap.Grants[i].UserID < snap.Grants[j].UserID })
+	sort.Slice(snap.Wizard, func(i, j int) bool { return snap.Wizard[i].ChatID < snap.Wizard[j].ChatID })
+	return snap
+}
+
+func restoreFrom(t *testing.T, s Storage, archive []byte) BackupManifest {
+	t.Helper()
+	manifest, err := Restore(context.Background(), s, bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	source := NewMemoryStorage()
+	fillStorage(t, source)
+
+	var buf bytes.Buffer
+	manifest, err := Backup(context.Background(), source, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BackupManifest{Version: BackupVersion, CreatedAt: manifest.CreatedAt, Profiles: 3, Photos: 2, Sessions: 1, Grants: 1, Audit: 2, Wizard: 1}
+	if manifest != want {
+		t.Errorf("manifest = %+v, want %+v", manifest, want)
+	}
+
+	target := NewMemoryStorage()
+	restored := restoreFrom(t, target, buf.Bytes())
+	if restored.Version != BackupVersion || restored.Profiles != manifest.Profiles {
+		t.Errorf("restored manifest = %+v, want %+v", restored, manifest)
+	}
+	if got, want := takeSnapshot(t, target), takeSnapshot(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored data differs:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestRestoreTwiceDoesNotDuplicateAudit(t *testing.T) {
+	source := NewMemoryStorage()
+	fillStorage(t, source)
+
+	var buf bytes.Buffer
+	if _, err := Backup(context.Background(), source, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	target := NewMemoryStorage()
+	restoreFrom(t, target, buf.Bytes())
+	restoreFrom(t, target, buf.Bytes())
+
+	if got, want := takeSnapshot(t, target), takeSnapshot(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("data after second restore differs:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestRestoreRejectsUnknownVersion(t *testing.T) {
+	var newer bytes.Buffer
+	archive := zip.NewWriter(&newer)
+	err := writeBackupJSON(archive, backupManifestFile, BackupManifest{Version: BackupVersion + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Restore(context.Background(), NewMemoryStorage(), bytes.NewReader(newer.Bytes()), int64(newer.Len()))
+	if err == nil {
+		t.Error("Restore accepted an archive of unsupported version")
+	}
+}
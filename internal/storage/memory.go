@@ -1,46 +1,243 @@
 package storage
 
 import (
-	"errors"
+	"context"
 	"sync"
 
 	"github.com/t1ery/MotoBot/internal/user"
 )
 
+// profileKey - анкета пользователя в конкретном сообществе
+type profileKey struct {
+	chatID int64
+	userID int
+}
+
+// grantKey - роль выдаётся пользователю в конкретном сообществе
+type grantKey struct {
+	chatID int64
+	userID int
+}
+
 type MemoryStorage struct {
-	data map[int]*user.Profile
-	mu   sync.Mutex
+	data     map[profileKey]*user.Profile
+	sessions map[int]*user.Session
+	grants   map[grantKey]*user.Grant
+	audit    []*user.AuditEntry
+	wizard   map[int64]*user.WizardStats
+	mu       sync.Mutex
 }
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		data: make(map[int]*user.Profile),
+		data:     make(map[profileKey]*user.Profile),
+		sessions: make(map[int]*user.Session),
+		grants:   make(map[grantKey]*user.Grant),
+		wizard:   make(map[int64]*user.WizardStats),
 	}
 }
 
-func (s *MemoryStorage) SaveProfile(profile *user.Profile) error {
+func (s *MemoryStorage) SaveProfile(ctx context.Context, profile *user.Profile) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data[profile.UserID] = profile
+	if profile.ChatID == 0 {
+		return ErrNoChat
+	}
+	s.data[profileKey{chatID: profile.ChatID, userID: profile.UserID}] = profile
 	return nil
 }
 
-func (s *MemoryStorage) GetProfile(userID int) (*user.Profile, error) {
+func (s *MemoryStorage) GetProfile(ctx context.Context, chatID int64, userID int) (*user.Profile, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	profile, found := s.data[userID]
+	profile, found := s.data[profileKey{chatID: chatID, userID: userID}]
 	if !found {
-		return nil, errors.New("profile not found")
+		return nil, ErrNotFound
 	}
 	return profile, nil
 }
 
-func (s *MemoryStorage) DeleteProfile(userID int) error {
+func (s *MemoryStorage) DeleteProfile(ctx context.Context, chatID int64, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, profileKey{chatID: chatID, userID: userID})
+	return nil
+}
+
+func (s *MemoryStorage) ListProfiles(ctx context.Context) ([]*user.Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles := make([]*user.Profile, 0, len(s.data))
+	for _, profile := range s.data {
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (s *MemoryStorage) QueryProfiles(ctx context.Context, query ProfileQuery) (ProfilePage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles := make([]*user.Profile, 0, len(s.data))
+	for _, profile := range s.data {
+		profiles = append(profiles, profile)
+	}
+	return paginate(profiles, query)
+}
+
+func (s *MemoryStorage) CountProfiles(ctx context.Context, query ProfileQuery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, profile := range s.data {
+		if query.Match(profile) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStorage) SaveSession(ctx context.Context, session *user.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.UserID] = session
+	return nil
+}
+
+func (s *MemoryStorage) GetSession(ctx context.Context, userID int) (*user.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, found := s.sessions[userID]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+func (s *MemoryStorage) DeleteSession(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+	return nil
+}
+
+func (s *MemoryStorage) ListSessions(ctx context.Context) ([]*user.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*user.Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *MemoryStorage) SaveGrant(ctx context.Context, grant *user.Grant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.grants[grantKey{chatID: grant.ChatID, userID: grant.UserID}] = grant
+	return nil
+}
+
+func (s *MemoryStorage) DeleteGrant(ctx context.Context, chatID int64, userID int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.data, userID)
+	delete(s.grants, grantKey{chatID: chatID, userID: userID})
+	return nil
+}
+
+func (s *MemoryStorage) ListGrants(ctx context.Context) ([]*user.Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grants := make([]*user.Grant, 0, len(s.grants))
+	for _, grant := range s.grants {
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+func (s *MemoryStorage) AppendAudit(ctx context.Context, entry *user.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func (s *MemoryStorage) ListAudit(ctx context.Context) ([]*user.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*user.AuditEntry, len(s.audit))
+	copy(entries, s.audit)
+	return entries, nil
+}
+
+func (s *MemoryStorage) DeleteAudit(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.audit[:0]
+	for _, entry := range s.audit {
+		switch {
+		case entry.UserID == userID:
+			continue
+		case entry.ActorID == userID:
+			// Запись о другом пользователе остаётся, но без идентификатора того, кто выполнил действие.
+			// Записи не изменяются, поэтому вместо правки сохраняется копия
+			anonymous := *entry
+			anonymous.ActorID = 0
+			entry = &anonymous
+		}
+		kept = append(kept, entry)
+	}
+	clear(s.audit[len(kept):])
+	s.audit = kept
+	return nil
+}
+
+func (s *MemoryStorage) SaveWizardStats(ctx context.Context, stats *user.WizardStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.wizard[stats.ChatID] = stats
+	return nil
+}
+
+func (s *MemoryStorage) GetWizardStats(ctx context.Context, chatID int64) (*user.WizardStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, found := s.wizard[chatID]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return stats, nil
+}
+
+func (s *MemoryStorage) ListWizardStats(ctx context.Context) ([]*user.WizardStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*user.WizardStats, 0, len(s.wizard))
+	for _, stats := range s.wizard {
+		list = append(list, stats)
+	}
+	return list, nil
+}
+
+// Close для хранилища в памяти ничего не делает
+func (s *MemoryStorage) Close() error {
 	return nil
 }
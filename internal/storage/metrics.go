@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/metrics"
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// InstrumentedStorage - обёртка над хранилищем, учитывающая время выполнения операций в метриках
+type InstrumentedStorage struct {
+	next Storage
+}
+
+// WithMetrics оборачивает хранилище сбором метрик
+func WithMetrics(next Storage) *InstrumentedStorage {
+	return &InstrumentedStorage{next: next}
+}
+
+// observeLookup учитывает в метриках получение одной записи. Отсутствие записи - обычный ответ,
+// а не ошибка хранилища, поэтому ErrNotFound в ошибки не попадает.
+func observeLookup(operation string, start time.Time, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	metrics.ObserveStorage(operation, start, err)
+}
+
+func (s *InstrumentedStorage) SaveProfile(ctx context.Context, profile *user.Profile) error {
+	start := time.Now()
+	err := s.next.SaveProfile(ctx, profile)
+	metrics.ObserveStorage("save_profile", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) GetProfile(ctx context.Context, chatID int64, userID int) (*user.Profile, error) {
+	start := time.Now()
+	profile, err := s.next.GetProfile(ctx, chatID, userID)
+	observeLookup("get_profile", start, err)
+	return profile, err
+}
+
+func (s *InstrumentedStorage) DeleteProfile(ctx context.Context, chatID int64, userID int) error {
+	start := time.Now()
+	err := s.next.DeleteProfile(ctx, chatID, userID)
+	metrics.ObserveStorage("delete_profile", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) ListProfiles(ctx context.Context) ([]*user.Profile, error) {
+	start := time.Now()
+	profiles, err := s.next.ListProfiles(ctx)
+	metrics.ObserveStorage("list_profiles", start, err)
+	return profiles, err
+}
+
+func (s *InstrumentedStorage) QueryProfiles(ctx context.Context, query ProfileQuery) (ProfilePage, error) {
+	start := time.Now()
+	page, err := s.next.QueryProfiles(ctx, query)
+	metrics.ObserveStorage("query_profiles", start, err)
+	return page, err
+}
+
+func (s *InstrumentedStorage) CountProfiles(ctx context.Context, query ProfileQuery) (int, error) {
+	start := time.Now()
+	count, err := s.next.CountProfiles(ctx, query)
+	metrics.ObserveStorage("count_profiles", start, err)
+	return count, err
+}
+
+func (s *InstrumentedStorage) SaveSession(ctx context.Context, session *user.Session) error {
+	start := time.Now()
+	err := s.next.SaveSession(ctx, session)
+	metrics.ObserveStorage("save_session", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) GetSession(ctx context.Context, userID int) (*user.Session, error) {
+	start := time.Now()
+	session, err := s.next.GetSession(ctx, userID)
+	observeLookup("get_session", start, err)
+	return session, err
+}
+
+func (s *InstrumentedStorage) DeleteSession(ctx context.Context, userID int) error {
+	start := time.Now()
+	err := s.next.DeleteSession(ctx, userID)
+	metrics.ObserveStorage("delete_session", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) ListSessions(ctx context.Context) ([]*user.Session, error) {
+	start := time.Now()
+	sessions, err := s.next.ListSessions(ctx)
+	metrics.ObserveStorage("list_sessions", start, err)
+	return sessions, err
+}
+
+func (s *InstrumentedStorage) SaveGrant(ctx context.Context, grant *user.Grant) error {
+	start := time.Now()
+	err := s.next.SaveGrant(ctx, grant)
+	metrics.ObserveStorage("save_grant", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) DeleteGrant(ctx context.Context, chatID int64, userID int) error {
+	start := time.Now()
+	err := s.next.DeleteGrant(ctx, chatID, userID)
+	metrics.ObserveStorage("delete_grant", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) ListGrants(ctx context.Context) ([]*user.Grant, error) {
+	start := time.Now()
+	grants, err := s.next.ListGrants(ctx)
+	metrics.ObserveStorage("list_grants", start, err)
+	return grants, err
+}
+
+func (s *InstrumentedStorage) AppendAudit(ctx context.Context, entry *user.AuditEntry) error {
+	start := time.Now()
+	err := s.next.AppendAudit(ctx, entry)
+	metrics.ObserveStorage("append_audit", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) ListAudit(ctx context.Context) ([]*user.AuditEntry, error) {
+	start := time.Now()
+	entries, err := s.next.ListAudit(ctx)
+	metrics.ObserveStorage("list_audit", start, err)
+	return entries, err
+}
+
+func (s *InstrumentedStorage) DeleteAudit(ctx context.Context, userID int) error {
+	start := time.Now()
+	err := s.next.DeleteAudit(ctx, userID)
+	metrics.ObserveStorage("delete_audit", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) SaveWizardStats(ctx context.Context, stats *user.WizardStats) error {
+	start := time.Now()
+	err := s.next.SaveWizardStats(ctx, stats)
+	metrics.ObserveStorage("save_wizard_stats", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) GetWizardStats(ctx context.Context, chatID int64) (*user.WizardStats, error) {
+	start := time.Now()
+	stats, err := s.next.GetWizardStats(ctx, chatID)
+	observeLookup("get_wizard_stats", start, err)
+	return stats, err
+}
+
+func (s *InstrumentedStorage) ListWizardStats(ctx context.Context) ([]*user.WizardStats, error) {
+	start := time.Now()
+	list, err := s.next.ListWizardStats(ctx)
+	metrics.ObserveStorage("list_wizard_stats", start, err)
+	return list, err
+}
+
+func (s *InstrumentedStorage) Close() error {
+	return s.next.Close()
+}
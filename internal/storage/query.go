@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// ErrNotFound возвращается, если запрошенной записи нет в хранилище
+var ErrNotFound = errors.New("not found")
+
+// ErrNoChat возвращается при сохранении анкеты без группы сообщества
+var ErrNoChat = errors.New("profile has no chat")
+
+// ErrInvalidCursor возвращается, если курсор страницы не получен из предыдущего ответа хранилища
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ProfileQuery - условия отбора анкет. Нулевые значения условий не ограничивают выборку
+type ProfileQuery struct {
+	ChatID int64 // Группа сообщества
+	UserID int   // Владелец анкет во всех группах
+	Driver *bool // Водитель или пассажир
+	MinAge int   // Минимальный возраст
+	MaxAge int   // Максимальный возраст
+	Hidden *bool // Скрытые или опубликованные анкеты
+
+	CreatedAfter time.Time // Созданные позже этого времени
+
+	Cursor string // Курсор страницы из предыдущего ответа, пустой - с начала
+	Limit  int    // Размер страницы, 0 - все подходящие анкеты
+}
+
+// ProfilePage - страница анкет, упорядоченных по идентификатору пользователя и группе
+type ProfilePage struct {
+	Profiles   []*user.Profile // Анкеты страницы
+	NextCursor string          // Курсор следующей страницы, пустой, если это последняя страница
+}
+
+// Match сообщает, подходит ли анкета под условия отбора. Курсор и размер страницы не учитываются
+func (q ProfileQuery) Match(profile *user.Profile) bool {
+	if q.ChatID != 0 && profile.ChatID != q.ChatID {
+		return false
+	}
+	if q.UserID != 0 && profile.UserID != q.UserID {
+		return false
+	}
+	if q.Driver != nil && profile.IsDriver != *q.Driver {
+		return false
+	}
+	if q.MinAge > 0 && profile.Age < q.MinAge {
+		return false
+	}
+	if q.MaxAge > 0 && profile.Age > q.MaxAge {
+		return false
+	}
+	if q.Hidden != nil && profile.Hidden != *q.Hidden {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && !profile.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// profileBefore задаёт порядок анкет в выдаче: по идентификатору пользователя, затем по группе.
+func profileBefore(a, b *user.Profile) bool {
+	if a.UserID != b.UserID {
+		return a.UserID < b.UserID
+	}
+	return a.ChatID < b.ChatID
+}
+
+// encodeCursor возвращает курсор, указывающий на анкету, после которой начнётся следующая страница.
+func encodeCursor(profile *user.Profile) string {
+	return strconv.Itoa(profile.UserID) + ":" + strconv.FormatInt(profile.ChatID, 10)
+}
+
+// decodeCursor возвращает анкету-ключ из курсора.
+func decodeCursor(cursor string) (*user.Profile, error) {
+	userPart, chatPart, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	userID, err := strconv.Atoi(userPart)
+	if err != nil || userID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	chatID, err := strconv.ParseInt(chatPart, 10, 64)
+	if err != nil || chatID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &user.Profile{UserID: userID, ChatID: chatID}, nil
+}
+
+// paginate отбирает анкеты по условиям и возвращает страницу, начиная с курсора.
+func paginate(profiles []*user.Profile, query ProfileQuery) (ProfilePage, error) {
+	var page ProfilePage
+
+	var after *user.Profile
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	matched := make([]*user.Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		if (after == nil || profileBefore(after, profile)) && query.Match(profile) {
+			matched = append(matched, profile)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return profileBefore(matched[i], matched[j]) })
+
+	if query.Limit > 0 && len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+		page.NextCursor = encodeCursor(matched[len(matched)-1])
+	}
+	page.Profiles = matched
+	return page, nil
+}
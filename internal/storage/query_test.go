@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// testProfiles возвращает анкеты двух групп вперемешку, чтобы проверять порядок выдачи.
+func testProfiles() []*user.Profile {
+	return []*user.Profile{
+		{UserID: 3, ChatID: -100, Age: 40},
+		{UserID: 1, ChatID: -200, Age: 20, IsDriver: true},
+		{UserID: 2, ChatID: -100, Age: 30, Hidden: true},
+		{UserID: 1, ChatID: -100, Age: 20, IsDriver: true},
+		{UserID: 4, ChatID: -200, Age: 50},
+	}
+}
+
+// pageKeys возвращает ключи анкет страницы в виде курсоров для короткого сравнения.
+func pageKeys(profiles []*user.Profile) []string {
+	keys := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		keys = append(keys, encodeCursor(profile))
+	}
+	return keys
+}
+
+func TestPaginateWalksAllPages(t *testing.T) {
+	profiles := testProfiles()
+
+	var got []string
+	var pages int
+	query := ProfileQuery{Limit: 2}
+	for {
+		page, err := paginate(profiles, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		got = append(got, pageKeys(page.Profiles)...)
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > len(profiles) {
+			t.Fatal("pagination does not end")
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	want := []string{"1:-200", "1:-100", "2:-100", "3:-100", "4:-200"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles = %v, want %v", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("pages = %d, want 3", pages)
+	}
+}
+
+func TestPaginateLastPage(t *testing.T) {
+	tests := []struct {
+		name  string
+		query ProfileQuery
+		want  []string
+	}{
+		{"limit equals rest", ProfileQuery{Cursor: "2:-100", Limit: 2}, []string{"3:-100", "4:-200"}},
+		{"limit exceeds rest", ProfileQuery{Cursor: "3:-100", Limit: 10}, []string{"4:-200"}},
+		{"no limit", ProfileQuery{}, []string{"1:-200", "1:-100", "2:-100", "3:-100", "4:-200"}},
+		{"cursor after last", ProfileQuery{Cursor: "4:-200", Limit: 2}, []string{}},
+		{"nothing matches", ProfileQuery{ChatID: -300, Limit: 2}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := paginate(testProfiles(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageKeys(page.Profiles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("profiles = %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor = %q on the last page, want empty", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestPaginateFiltersBeforeLimit(t *testing.T) {
+	hidden := false
+	page, err := paginate(testProfiles(), ProfileQuery{ChatID: -100, Hidden: &hidden, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pageKeys(page.Profiles), []string{"1:-100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles = %v, want %v", got, want)
+	}
+	if page.NextCursor != "1:-100" {
+		t.Fatalf("NextCursor = %q, want %q", page.NextCursor, "1:-100")
+	}
+
+	page, err = paginate(testProfiles(), ProfileQuery{ChatID: -100, Hidden: &hidden, Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pageKeys(page.Profiles), []string{"3:-100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles = %v, want %v", got, want)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("NextCursor = %q on the last page, want empty", page.NextCursor)
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"abc", "1", "1:", ":-100", "0:-100", "-1:-100", "1:0", "x:-100", "1:y"} {
+		t.Run(cursor, func(t *testing.T) {
+			_, err := paginate(testProfiles(), ProfileQuery{Cursor: cursor, Limit: 2})
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// memoryWithProfiles возвращает хранилище в памяти с анкетами testProfiles. Анкеты первого
+// пользователя созданы давно, остальные - недавно.
+func memoryWithProfiles(t *testing.T, now time.Time) *MemoryStorage {
+	t.Helper()
+	s := NewMemoryStorage()
+	for _, profile := range testProfiles() {
+		profile.CreatedAt = now.Add(-time.Hour)
+		if profile.UserID == 1 {
+			profile.CreatedAt = now.Add(-30 * 24 * time.Hour)
+		}
+		if err := s.SaveProfile(context.Background(), profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestMemoryStorageCountProfiles(t *testing.T) {
+	now := time.Now()
+	s := memoryWithProfiles(t, now)
+	yes, no := true, false
+
+	tests := []struct {
+		name  string
+		query ProfileQuery
+		want  int
+	}{
+		{"all", ProfileQuery{}, 5},
+		{"chat", ProfileQuery{ChatID: -100}, 3},
+		{"drivers", ProfileQuery{ChatID: -100, Driver: &yes}, 1},
+		{"passengers", ProfileQuery{ChatID: -100, Driver: &no}, 2},
+		{"hidden", ProfileQuery{ChatID: -100, Hidden: &yes}, 1},
+		{"published", ProfileQuery{ChatID: -100, Hidden: &no}, 2},
+		{"created after", ProfileQuery{ChatID: -100, CreatedAfter: now.Add(-7 * 24 * time.Hour)}, 2},
+		{"created after everything", ProfileQuery{CreatedAfter: now}, 0},
+		{"age range", ProfileQuery{MinAge: 25, MaxAge: 45}, 2},
+		{"max age only", ProfileQuery{MaxAge: 17}, 0},
+		{"min age only", ProfileQuery{MinAge: 45}, 1},
+		{"exact age", ProfileQuery{MinAge: 20, MaxAge: 20}, 2},
+		{"user", ProfileQuery{UserID: 1}, 2},
+		{"several conditions", ProfileQuery{ChatID: -200, Driver: &no, Hidden: &no, MinAge: 45}, 1},
+		{"cursor and limit are ignored", ProfileQuery{ChatID: -100, Cursor: "9:-100", Limit: 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CountProfiles(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CountProfiles = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageQueryInvalidCursor(t *testing.T) {
+	s := memoryWithProfiles(t, time.Now())
+	page, err := s.QueryProfiles(context.Background(), ProfileQuery{Cursor: "not-a-cursor", Limit: 2})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("err = %v, want ErrInvalidCursor", err)
+	}
+	if len(page.Profiles) != 0 || page.NextCursor != "" {
+		t.Errorf("page = %+v, want empty page on error", page)
+	}
+}
@@ -1,9 +1,38 @@
 package storage
 
-import "github.com/t1ery/MotoBot/internal/user"
+import (
+	"context"
 
+	"github.com/t1ery/MotoBot/internal/user"
+)
+
+// Storage - хранилище данных бота. Анкеты хранятся отдельно для каждой группы сообщества:
+// у пользователя может быть по анкете в каждой группе. Методы получения одной записи возвращают ErrNotFound, если её нет
 type Storage interface {
-	SaveProfile(profile *user.Profile) error      // Сохраняет информацию о пользователе в БД
-	GetProfile(userID int) (*user.Profile, error) // Получает информацию о пользователе
-	DeleteProfile(userID int) error               // Удаляет профиль из БД
+	SaveProfile(ctx context.Context, profile *user.Profile) error                    // Сохраняет анкету пользователя в группе profile.ChatID
+	GetProfile(ctx context.Context, chatID int64, userID int) (*user.Profile, error) // Получает анкету пользователя в группе
+	DeleteProfile(ctx context.Context, chatID int64, userID int) error               // Удаляет анкету пользователя в группе
+	ListProfiles(ctx context.Context) ([]*user.Profile, error)                       // Получает все анкеты всех групп
+
+	QueryProfiles(ctx context.Context, query ProfileQuery) (ProfilePage, error) // Получает страницу анкет, подходящих под условия
+	CountProfiles(ctx context.Context, query ProfileQuery) (int, error)         // Считает анкеты, подходящие под условия
+
+	SaveSession(ctx context.Context, session *user.Session) error      // Сохраняет незавершённое заполнение анкеты
+	GetSession(ctx context.Context, userID int) (*user.Session, error) // Получает незавершённое заполнение анкеты
+	DeleteSession(ctx context.Context, userID int) error               // Удаляет незавершённое заполнение анкеты
+	ListSessions(ctx context.Context) ([]*user.Session, error)         // Получает все незавершённые заполнения анкет
+
+	SaveGrant(ctx context.Context, grant *user.Grant) error          // Сохраняет выданную пользователю роль
+	DeleteGrant(ctx context.Context, chatID int64, userID int) error // Отзывает выданную пользователю роль
+	ListGrants(ctx context.Context) ([]*user.Grant, error)           // Получает все выданные роли
+
+	AppendAudit(ctx context.Context, entry *user.AuditEntry) error // Добавляет запись в журнал аудита
+	ListAudit(ctx context.Context) ([]*user.AuditEntry, error)     // Получает все записи журнала аудита в порядке добавления
+	DeleteAudit(ctx context.Context, userID int) error             // Удаляет записи журнала о пользователе и убирает его из записей о других по его просьбе
+
+	SaveWizardStats(ctx context.Context, stats *user.WizardStats) error          // Сохраняет итоги заполнения анкеты в группе
+	GetWizardStats(ctx context.Context, chatID int64) (*user.WizardStats, error) // Получает итоги заполнения анкеты в группе
+	ListWizardStats(ctx context.Context) ([]*user.WizardStats, error)            // Получает итоги заполнения анкеты во всех группах
+
+	Close() error // Освобождает ресурсы хранилища
 }
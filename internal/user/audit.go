@@ -0,0 +1,97 @@
+package user
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// Действия, которые записываются в журнал аудита
+const (
+	AuditCreated = "create" // Анкета создана
+	AuditEdited  = "edit"   // Анкета отредактирована
+	AuditDeleted = "delete" // Анкета удалена
+	AuditHidden  = "hide"   // Анкета снята из группы владельцем или ботом
+	AuditShown   = "show"   // Анкета возвращена в группу
+	AuditExpired = "expire" // Анкета снята, так как владелец не подтвердил её актуальность
+	AuditGranted = "grant"  // Пользователю выдана роль
+	AuditRevoked = "revoke" // С пользователя снята роль
+)
+
+// AuditEntry - запись журнала аудита. Записи только добавляются и не изменяются
+type AuditEntry struct {
+	ChatID    int64         // Группа сообщества, к которой относится действие
+	UserID    int           // Пользователь, анкеты или роли которого касается действие
+	ActorID   int           // Кто выполнил действие, 0 - сам бот или пользователь, удаливший свои данные
+	Action    string        // Действие
+	Changes   []FieldChange // Изменённые поля анкеты при редактировании
+	Comment   string        // Дополнительные сведения, например причина действия бота
+	CreatedAt time.Time     // Время действия
+}
+
+// FieldChange - изменение поля анкеты
+type FieldChange struct {
+	Field string // Название поля, как в StepName
+	Old   string // Прежнее значение
+	New   string // Новое значение
+}
+
+// AuditActionName возвращает описание действия для сообщений
+func AuditActionName(action string) string {
+	switch action {
+	case AuditCreated:
+		return "создание анкеты"
+	case AuditEdited:
+		return "редактирование анкеты"
+	case AuditDeleted:
+		return "удаление анкеты"
+	case AuditHidden:
+		return "скрытие анкеты"
+	case AuditShown:
+		return "возвращение анкеты в группу"
+	case AuditExpired:
+		return "снятие неактуальной анкеты"
+	case AuditGranted:
+		return "выдача роли"
+	case AuditRevoked:
+		return "снятие роли"
+	default:
+		return action
+	}
+}
+
+// DiffProfiles возвращает поля анкеты, которые заполняет пользователь и которые отличаются в двух версиях
+func DiffProfiles(before, after *Profile) []FieldChange {
+	var changes []FieldChange
+	add := func(step int, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: StepName(step), Old: old, New: new})
+		}
+	}
+
+	add(StepFirstName, before.FirstName, after.FirstName)
+	add(StepLastName, before.LastName, after.LastName)
+	add(StepAge, strconv.Itoa(before.Age), strconv.Itoa(after.Age))
+	add(StepCity, before.City, after.City)
+	add(StepIsDriver, strconv.FormatBool(before.IsDriver), strconv.FormatBool(after.IsDriver))
+	add(StepInterests, before.Interests, after.Interests)
+	add(StepContacts, before.Contacts, after.Contacts)
+
+	// Сама фотография в журнал не попадает, только факт её изменения
+	if !bytes.Equal(before.Photo, after.Photo) || before.PhotoFileID != after.PhotoFileID {
+		change := FieldChange{Field: StepName(StepPhoto), Old: photoState(before), New: photoState(after)}
+		if change.Old == change.New {
+			change.New = "заменена"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// photoState описывает фотографию анкеты для журнала аудита
+func photoState(profile *Profile) string {
+	if len(profile.Photo) == 0 && profile.PhotoFileID == "" {
+		return "нет"
+	}
+	return "есть"
+}
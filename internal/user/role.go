@@ -0,0 +1,39 @@
+package user
+
+import "time"
+
+// Role - роль пользователя в сообществе. Роли упорядочены: каждая следующая включает права предыдущих
+type Role int
+
+// Роли пользователей
+const (
+	RoleMember    Role = iota // Участник группы
+	RoleOrganizer             // Организатор покатушек
+	RoleAdmin                 // Администратор сообщества
+	RoleOwner                 // Владелец бота
+)
+
+// RoleName возвращает название роли для сообщений и логов
+func RoleName(role Role) string {
+	switch role {
+	case RoleMember:
+		return "member"
+	case RoleOrganizer:
+		return "organizer"
+	case RoleAdmin:
+		return "admin"
+	case RoleOwner:
+		return "owner"
+	default:
+		return "unknown"
+	}
+}
+
+// Grant - роль, выданная пользователю в сообществе командой /grant
+type Grant struct {
+	ChatID    int64     // Группа сообщества
+	UserID    int       // Пользователь, которому выдана роль
+	Role      Role      // Выданная роль
+	GrantedBy int       // Кто выдал роль, 0 - пользователь, удаливший свои данные
+	GrantedAt time.Time // Когда выдана роль
+}
@@ -0,0 +1,22 @@
+package user
+
+import "time"
+
+// Session - незавершённое заполнение анкеты пользователем
+type Session struct {
+	UserID    int       // Идентификатор пользователя
+	ChatID    int64     // Группа, в которую будет отправлена анкета
+	Step      int       // Текущий шаг создания анкеты
+	Furthest  int       // Самый дальний шаг, до которого дошёл пользователь
+	Review    bool      // После ответа вернуться к предпросмотру, а не к следующему шагу
+	Editing   bool      // Редактирование уже опубликованной анкеты
+	Profile   Profile   // Уже заполненные поля анкеты
+	UpdatedAt time.Time // Время последнего изменения
+}
+
+// WizardStats - итоги заполнения анкеты в группе
+type WizardStats struct {
+	ChatID    int64 // Группа, в которую заполнялись анкеты
+	Completed int   // Сколько заполнений закончилось публикацией анкеты
+	Abandoned int   // Сколько заполнений отменено или брошено по таймауту
+}
@@ -1,16 +1,55 @@
 package user
 
+import "time"
+
 // Profile - структура для анкеты пользователя
 type Profile struct {
-	UserID    int    // Идентификатор пользователя
-	FirstName string // Имя
-	LastName  string // Фамилия
-	Age       int    // Возраст
-	Interests string // Интересы
-	Photo     []byte // Фотография пользователя
-	Contacts  string // Контактная информация пользователя
-	IsDriver  bool   // Является ли пользователь водителем
-	MessageID int    // Номер сообщения размещения анкеты в группе
+	UserID      int    // Идентификатор пользователя
+	ChatID      int64  // Группа сообщества, в которой публикуется анкета. Вместе с UserID определяет анкету
+	FirstName   string // Имя
+	LastName    string // Фамилия
+	Age         int    // Возраст
+	City        string // Город
+	Interests   string // Интересы
+	Photo       []byte // Фотография пользователя
+	PhotoFileID string // Идентификатор фотографии на серверах Telegram, чтобы не загружать её повторно
+	Contacts    string // Контактная информация пользователя
+	IsDriver    bool   // Является ли пользователь водителем
+	MessageID   int    // Номер сообщения размещения анкеты в группе
+	Hidden      bool   // Анкета скрыта владельцем: снята из группы и не участвует в поиске
+	Expired     bool   // Анкета снята из группы, так как владелец не подтвердил её актуальность
+
+	CreatedAt   time.Time // Время создания анкеты
+	UpdatedAt   time.Time // Время последнего изменения анкеты
+	ConfirmedAt time.Time // Время последнего подтверждения актуальности владельцем
+	AskedAt     time.Time // Время вопроса об актуальности, на который владелец ещё не ответил
+	BumpedAt    time.Time // Время последнего поднятия анкеты командой /up
+	LeftAt      time.Time // Время выхода владельца из группы, если он ещё не вернулся
+}
+
+// LastActiveAt возвращает время последнего действия владельца с анкетой
+func (p *Profile) LastActiveAt() time.Time {
+	last := p.UpdatedAt
+	for _, t := range []time.Time{p.ConfirmedAt, p.BumpedAt} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// CopyAnswers переносит в анкету ответы на вопросы анкеты из другой анкеты. Служебные поля:
+// сообщение в группе, скрытие и время действий с анкетой - не меняются
+func (p *Profile) CopyAnswers(from *Profile) {
+	p.FirstName = from.FirstName
+	p.LastName = from.LastName
+	p.Age = from.Age
+	p.City = from.City
+	p.IsDriver = from.IsDriver
+	p.Interests = from.Interests
+	p.Photo = from.Photo
+	p.PhotoFileID = from.PhotoFileID
+	p.Contacts = from.Contacts
 }
 
 // Step constants - шаги создания анкеты
@@ -18,9 +57,44 @@ const (
 	StepFirstName = iota
 	StepLastName
 	StepAge
+	StepCity
 	StepIsDriver
 	StepInterests
 	StepPhoto
 	StepContacts
+	StepPreview   // Предпросмотр анкеты перед публикацией
 	StepCompleted // Завершено создание анкеты
 )
+
+// StepName возвращает название шага создания анкеты для логов и метрик
+func StepName(step int) string {
+	switch step {
+	case StepFirstName:
+		return "first_name"
+	case StepLastName:
+		return "last_name"
+	case StepAge:
+		return "age"
+	case StepCity:
+		return "city"
+	case StepIsDriver:
+		return "is_driver"
+	case StepInterests:
+		return "interests"
+	case StepPhoto:
+		return "photo"
+	case StepContacts:
+		return "contacts"
+	case StepPreview:
+		return "preview"
+	case StepCompleted:
+		return "completed"
+	default:
+		return "unknown"
+	}
+}
+
+// IsOptionalStep сообщает, можно ли пропустить шаг создания анкеты
+func IsOptionalStep(step int) bool {
+	return step == StepCity || step == StepPhoto || step == StepContacts
+}